@@ -0,0 +1,101 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// awkUtilities are the names of the pages documenting the awk language.
+var awkUtilities = map[string]bool{
+	"awk": true, "gawk": true, "mawk": true, "nawk": true,
+}
+
+// awkSkipSections are the sections of an awk page that document the
+// utility rather than the language, such as the environment variables
+// whose upper-case tags look like awk's built-in variables.
+var awkSkipSections = map[string]bool{
+	"NAME": true, "SYNOPSIS": true, "OPTIONS": true, "OPERANDS": true,
+	"ENVIRONMENT": true, "ENVIRONMENT VARIABLES": true,
+}
+
+var (
+	// awkVariable matches the tags of awk's built-in variables, such as
+	// "NR" or "FILENAME".
+	awkVariable = regexp.MustCompile(`^[A-Z][A-Z0-9_]+$`)
+
+	// awkFunction matches the tags of awk's built-in functions, such as
+	// "substr(s, m[, n ])", "rand()" or "length[([s])]".
+	awkFunction = regexp.MustCompile(`^([a-z][a-z0-9_]*)(?:\(|\[\()`)
+
+	// awkGetline matches the forms of getline, such as "getline [var] <
+	// expression" or "expression | getline [var]".
+	awkGetline = regexp.MustCompile(`(?:^|\s)getline(?:\s|$)`)
+)
+
+// An awkBuiltin is a built-in function or variable of awk.
+type awkBuiltin struct {
+	Name string
+	Kind string // "function" or "variable"
+
+	// Signature is the synopsis of a function, such as "substr(s, m[, n
+	// ])", with the forms of functions documented in several forms, like
+	// getline, separated by "; ". It is "array" for array variables.
+	Signature string
+	Doc       string
+
+	// Start and End are the byte offsets of Name in the page text.
+	Start, End int
+}
+
+// awkBuiltins returns the built-in functions and variables that p
+// documents, if it is a page of the awk language.
+func (p *manPage) awkBuiltins() []*awkBuiltin {
+	if !awkUtilities[p.Name] {
+		return nil
+	}
+	var builtins []*awkBuiltin
+	byName := map[string]*awkBuiltin{}
+	for _, s := range p.Sections {
+		if awkSkipSections[s.Title] {
+			continue
+		}
+		lines := p.lines(s.Title)
+		for _, e := range p.entriesIn(lines) {
+			// Only the parts of the page about variables or functions
+			// are searched, as other tags, such as the fields of
+			// mktime's date specification or lines of examples, may
+			// look the same.
+			heading := strings.ToLower(subsectionAt(lines, e.TagStart, s.Title))
+			vars := strings.Contains(heading, "variable")
+			funcs := strings.Contains(heading, "function") || strings.Contains(heading, "input") || strings.Contains(heading, "output")
+
+			tag := normalizeDashes(e.Tag)
+			b := &awkBuiltin{Doc: e.Doc, Start: e.TagStart}
+			switch {
+			case vars && awkVariable.MatchString(tag):
+				b.Name, b.Kind = tag, "variable"
+				if doc := strings.ToLower(e.Doc); strings.HasPrefix(doc, "an array") || strings.HasPrefix(doc, "array") {
+					b.Signature = "array"
+				}
+			case funcs && awkGetline.MatchString(tag):
+				b.Name, b.Kind, b.Signature = "getline", "function", tag
+				b.Start += strings.Index(e.Tag, "getline")
+			case funcs && awkFunction.MatchString(tag):
+				b.Name, b.Kind, b.Signature = awkFunction.FindStringSubmatch(tag)[1], "function", tag
+			default:
+				continue
+			}
+			b.End = b.Start + len(b.Name)
+
+			if prev, ok := byName[b.Name]; ok {
+				if prev.Kind == "function" && b.Kind == "function" {
+					prev.Signature += "; " + b.Signature
+				}
+				continue
+			}
+			byName[b.Name] = b
+			builtins = append(builtins, b)
+		}
+	}
+	return builtins
+}
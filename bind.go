@@ -0,0 +1,298 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// A binding is a piece of a simple command, such as the command name, a
+// single option from a group of flags or an operand, together with the
+// def that documents it.
+type binding struct {
+	Text string
+	Kind string     // "command", "option", "primary", "operator", "argument", "operand", "signal", "end-of-options", "terminator" or "unknown"
+	Def  *graph.Def // the documenting def, or nil if unknown
+
+	// Command is the binding of the command the word belongs to, which
+	// differs from the first for the words of a nested command, as in
+	// "xargs rm -f". It is the binding itself for command names.
+	Command *binding
+
+	// Start and End are the byte offsets of Text in the source.
+	Start, End int
+}
+
+// bindCommand binds the words of a simple command to the defs of the
+// command, its options and operands, following the POSIX Utility Syntax
+// Guidelines: grouped flags such as "-xzvf" are split, option-arguments
+// are taken from the rest of the group or from the next word, and "--"
+// ends the options. Long options ("--name" or "--name=value") and
+// single-dash multi-character options are recognized when the page
+// documents them. So are the primaries and operators of expression
+// languages, such as find's "-name pattern" or test's "-eq", wherever
+// they appear.
+//
+// The nested commands of wrapper utilities, introduced by an operand or
+// primary whose def is marked Nested, are bound in turn: "xargs rm -f"
+// binds "rm" and "-f" to the defs of rm, and "find . -exec rm {} +"
+// binds the words up to the terminator "+" likewise.
+//
+// Signals given to kill and trap are bound to the defs of the signals:
+// kill's "-TERM", "-9" and the option-argument of "-s TERM", and the
+// conditions of trap, which follow its action unless the first operand
+// is an unsigned integer, as in "trap 0 INT".
+//
+// Options that supply an operand, such as sed's -e and -f, take its
+// place: in "sed -e s/a/b/ file", "file" is the file operand.
+func bindCommand(index *defIndex, words []shellToken) []*binding {
+	if len(words) == 0 {
+		return nil
+	}
+	name := words[0]
+	cmd := index.command(name.Text)
+	if cmd == nil {
+		b := &binding{Text: name.Text, Kind: "unknown", Start: name.Start, End: name.End}
+		b.Command = b
+		return []*binding{b}
+	}
+	first := &binding{Text: name.Text, Kind: "command", Def: cmd, Start: name.Start, End: name.End}
+	first.Command = first
+	bindings := []*binding{first}
+	signalGiven := false
+	add := func(bs ...*binding) {
+		for _, b := range bs {
+			b.Command = first
+			if b.Kind == "argument" && b.Def != nil && defData(b.Def).Type == "signal" {
+				if sig := index.signal(b.Text); sig != nil {
+					b.Kind, b.Def = "signal", sig
+				}
+			}
+			if b.Kind == "signal" {
+				signalGiven = true
+			}
+		}
+		bindings = append(bindings, bs...)
+	}
+
+	var operands []*graph.Def
+	for _, def := range index.membersOf(cmd) {
+		if def.Kind == "operand" {
+			operands = append(operands, def)
+		}
+	}
+
+	endOfOptions := false
+	nOperands := 0
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		if def := index.member(cmd, w.Text); def != nil && (def.Kind == "primary" || def.Kind == "operator") {
+			add(&binding{Text: w.Text, Kind: def.Kind, Def: def, Start: w.Start, End: w.End})
+			if data := defData(def); data.Nested {
+				end := nestedEnd(words, i+1, data.Terminators)
+				bindings = append(bindings, bindCommand(index, words[i+1:end])...)
+				if end < len(words) {
+					t := words[end]
+					add(&binding{Text: t.Text, Kind: "terminator", Def: def, Start: t.Start, End: t.End})
+				}
+				i = end
+				continue
+			}
+			for n := argWords(defData(def).Arg); n > 0 && i+1 < len(words); n-- {
+				i++
+				next := words[i]
+				add(&binding{Text: next.Text, Kind: "argument", Def: def, Start: next.Start, End: next.End})
+			}
+			continue
+		}
+		switch {
+		// Once kill has its signal, "-1" is a process group.
+		case endOfOptions || w.Text == "-" || !isOptionWord(w.Text) || cmd.Name == "kill" && signalGiven && isUnsigned(w.Text[1:]):
+			var def *graph.Def
+			if len(operands) > 0 {
+				// The last operand is usually repeatable ("file...").
+				// So are assignments such as env's "name=value", which
+				// are optional.
+				def = operands[minInt(nOperands, len(operands)-1)]
+				for strings.Contains(def.Name, "=") && !strings.Contains(w.Text, "=") && nOperands < len(operands)-1 {
+					nOperands++
+					def = operands[nOperands]
+				}
+			}
+			if def != nil && defData(def).Nested {
+				return append(bindings, bindCommand(index, words[i:])...)
+			}
+			if cmd.Name == "trap" && (nOperands > 0 || isUnsigned(w.Text)) {
+				if sig := index.signal(w.Text); sig != nil {
+					nOperands++
+					add(&binding{Text: w.Text, Kind: "signal", Def: sig, Start: w.Start, End: w.End})
+					continue
+				}
+			}
+			if def == nil || !strings.Contains(def.Name, "=") {
+				nOperands++
+			}
+			add(&binding{Text: w.Text, Kind: "operand", Def: def, Start: w.Start, End: w.End})
+
+		case w.Text == "--":
+			endOfOptions = true
+			add(&binding{Text: w.Text, Kind: "end-of-options", Start: w.Start, End: w.End})
+
+		case cmd.Name == "kill" && !signalGiven && w.Text[0] == '-' && index.member(cmd, w.Text) == nil && index.signal(w.Text[1:]) != nil:
+			add(&binding{Text: w.Text, Kind: "signal", Def: index.signal(w.Text[1:]), Start: w.Start, End: w.End})
+
+		default:
+			bs, takesNext := bindOption(index, cmd, w)
+			add(bs...)
+			operands = dropSupplied(operands, nOperands, bs)
+			if takesNext && i+1 < len(words) {
+				i++
+				next := words[i]
+				add(&binding{Text: next.Text, Kind: "argument", Def: bs[len(bs)-1].Def, Start: next.Start, End: next.End})
+			}
+		}
+	}
+	return bindings
+}
+
+// dropSupplied removes from operands those the options bound in bs
+// supply, as sed's -e supplies the script, unless already bound: the
+// first next operands have been.
+func dropSupplied(operands []*graph.Def, next int, bs []*binding) []*graph.Def {
+	for _, b := range bs {
+		if b.Kind != "option" || b.Def == nil {
+			continue
+		}
+		supplied := defData(b.Def).SuppliesOperand
+		for i := next; i < len(operands); i++ {
+			if supplied != "" && operands[i].Name == supplied {
+				operands = append(operands[:i:i], operands[i+1:]...)
+				break
+			}
+		}
+	}
+	return operands
+}
+
+// nestedEnd returns the index of the word ending the nested command
+// that starts at words[start], or len(words) if it runs to the end. A
+// "+" only ends it after "{}", as with find's -exec.
+func nestedEnd(words []shellToken, start int, terminators []string) int {
+	for i := start + 1; i < len(words); i++ {
+		switch t := words[i].Text; {
+		case !contains(terminators, t):
+		case t == "+" && words[i-1].Text != "{}":
+		default:
+			return i
+		}
+	}
+	return len(words)
+}
+
+// isUnsigned reports whether word is an unsigned decimal integer.
+func isUnsigned(word string) bool {
+	return word != "" && strings.Trim(word, "0123456789") == ""
+}
+
+// isOptionWord reports whether word looks like one or more options.
+func isOptionWord(word string) bool {
+	return len(word) > 1 && (word[0] == '-' || word[0] == '+')
+}
+
+// bindOption binds a word holding one or more options. It reports
+// whether the last option takes its argument from the next word.
+func bindOption(index *defIndex, cmd *graph.Def, w shellToken) ([]*binding, bool) {
+	// Only words without quotes have source offsets for each byte.
+	offset := func(i int) int {
+		if w.quoted() {
+			return w.Start
+		}
+		return w.Start + i
+	}
+	end := func(i int) int {
+		if w.quoted() {
+			return w.End
+		}
+		return w.Start + i
+	}
+
+	// A whole-word option: "--long", "--long=value", "-name" or "+n".
+	// Optional arguments of long options must be given with "=".
+	name, value, hasValue := w.Text, "", false
+	if strings.HasPrefix(name, "--") {
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name, value, hasValue = name[:i], name[i+1:], true
+		}
+	}
+	if def := index.member(cmd, name); def != nil || strings.HasPrefix(name, "--") || name[0] == '+' {
+		kind := "option"
+		if def == nil {
+			kind = "unknown"
+		}
+		bs := []*binding{{Text: name, Kind: kind, Def: def, Start: offset(0), End: end(len(name))}}
+		if hasValue {
+			bs = append(bs, &binding{Text: value, Kind: "argument", Def: def, Start: offset(len(name) + 1), End: end(len(w.Text))})
+			return bs, false
+		}
+		return bs, def != nil && takesArgument(def) && !defData(def).ArgOptional
+	}
+
+	// A group of single-character flags, the last of which may take an
+	// argument: "-xzvf archive.tgz" or "-ofile". The span of the first
+	// flag includes the dash.
+	var bs []*binding
+	for i := 1; i < len(w.Text); i++ {
+		flag := "-" + w.Text[i:i+1]
+		start := offset(i)
+		if i == 1 {
+			start = offset(0)
+		}
+		def := index.member(cmd, flag)
+		if def == nil {
+			bs = append(bs, &binding{Text: flag, Kind: "unknown", Start: start, End: end(i + 1)})
+			continue
+		}
+		bs = append(bs, &binding{Text: flag, Kind: "option", Def: def, Start: start, End: end(i + 1)})
+		if takesArgument(def) {
+			if rest := w.Text[i+1:]; rest != "" {
+				bs = append(bs, &binding{Text: rest, Kind: "argument", Def: def, Start: offset(i + 1), End: end(len(w.Text))})
+				return bs, false
+			}
+			// An optional argument must be attached to its option.
+			return bs, !defData(def).ArgOptional
+		}
+	}
+	return bs, false
+}
+
+// takesArgument reports whether the option defined by def takes an
+// option-argument.
+func takesArgument(def *graph.Def) bool {
+	return defData(def).Arg != ""
+}
+
+// argWords returns the number of words taken as arguments by a primary
+// or operator whose tag continues with arg, such as "pattern" or
+// "utility_name [argument ...] ;": the placeholders before the first
+// optional or repeated one. Sub-expressions, as in "! expression", are
+// bound on their own.
+func argWords(arg string) int {
+	n := 0
+	for _, f := range strings.Fields(arg) {
+		if strings.HasPrefix(f, "[") || strings.Contains(f, "...") || strings.IndexFunc(f, unicode.IsLetter) < 0 ||
+			strings.HasPrefix(strings.ToLower(f), "expr") {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// minInt returns the lesser of a and b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
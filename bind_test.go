@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// testDef makes a def of the POSIX unit named by the last element of
+// path.
+func testDef(t *testing.T, path, kind string, data DefData) *graph.Def {
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := path[strings.LastIndex(path, "/")+1:]
+	return &graph.Def{DefKey: graph.DefKey{UnitType: "ManPages", Unit: "posix", Path: path}, Name: name, Kind: kind, Data: b}
+}
+
+func testIndex(t *testing.T) *defIndex {
+	var defs []*graph.Def
+	add := func(path, kind string, data DefData) {
+		defs = append(defs, testDef(t, path, kind, data))
+	}
+	add("sed.1p/sed", "command", DefData{})
+	add("sed.1p/sed/-n", "option", DefData{})
+	add("sed.1p/sed/-e", "option", DefData{Arg: "script", SuppliesOperand: "script"})
+	add("sed.1p/sed/-f", "option", DefData{Arg: "script_file", SuppliesOperand: "script"})
+	add("sed.1p/sed/script", "operand", DefData{})
+	add("sed.1p/sed/file", "operand", DefData{})
+	add("grep.1p/grep", "command", DefData{})
+	add("grep.1p/grep/-e", "option", DefData{Arg: "pattern_list", SuppliesOperand: "pattern_list"})
+	add("grep.1p/grep/-i", "option", DefData{})
+	add("grep.1p/grep/pattern_list", "operand", DefData{})
+	add("grep.1p/grep/file", "operand", DefData{})
+	add("env.1p/env", "command", DefData{})
+	add("env.1p/env/name=value", "operand", DefData{})
+	add("env.1p/env/utility", "operand", DefData{})
+	return newDefIndex(&graph.Output{Defs: defs})
+}
+
+func TestBindOperands(t *testing.T) {
+	index := testIndex(t)
+	tests := []struct {
+		line string
+		want string // each binding as text=def, def "-" if unknown
+	}{
+		{"sed 's/a/b/' file", "sed=sed s/a/b/=script file=file"},
+		{"sed -n 's/a/b/' a b", "sed=sed -n=-n s/a/b/=script a=file b=file"},
+		{"sed -n -e 's/a/b/g' file", "sed=sed -n=-n -e=-e s/a/b/g=-e file=file"},
+		{"sed -ne p file", "sed=sed -n=-n -e=-e p=-e file=file"},
+		{"sed -f x.sed -e p a b", "sed=sed -f=-f x.sed=-f -e=-e p=-e a=file b=file"},
+		{"sed -- -e file", "sed=sed --=- -e=script file=file"},
+		{"grep PAT file", "grep=grep PAT=pattern_list file=file"},
+		{"grep -i -e PAT file", "grep=grep -i=-i -e=-e PAT=-e file=file"},
+		{"env A=1 B=2 ls", "env=env A=1=name=value B=2=name=value ls=utility"},
+		{"nosuch -e x", "nosuch=-"},
+	}
+	for _, test := range tests {
+		var words []shellToken
+		for _, tok := range tokenizeShell(test.line) {
+			tok.Text = strings.Trim(tok.Text, "'")
+			words = append(words, tok)
+		}
+		var got []string
+		for _, b := range bindCommand(index, words) {
+			def := "-"
+			if b.Def != nil {
+				def = b.Def.Name
+			}
+			got = append(got, b.Text+"="+def)
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%s: got %s, want %s", test.line, strings.Join(got, " "), test.want)
+		}
+	}
+}
+
+func TestSupplyOperands(t *testing.T) {
+	text := `SYNOPSIS
+       sed [−n] script [file...]
+
+       sed [−n] −e script [−e script]... [−f script_file]... [file...]
+
+       sed [−n] [−e script]... −f script_file [−f script_file]... [file...]
+`
+	p := parsePage("sed.1p.txt", "sed", "1p", text)
+	opts := []*pageOption{
+		{Name: "-n"},
+		{Name: "-e", Arg: "script", Aliases: []string{"--expression"}},
+		{Name: "--expression", Arg: "script", Aliases: []string{"-e"}},
+		{Name: "-f", Arg: "script_file"},
+	}
+	operands := []*pageOption{{Name: "script"}, {Name: "file"}}
+	p.supplyOperands(opts, operands)
+	want := map[string]string{"-n": "", "-e": "script", "--expression": "script", "-f": "script"}
+	for _, o := range opts {
+		if o.SuppliesOperand != want[o.Name] {
+			t.Errorf("%s supplies %q, want %q", o.Name, o.SuppliesOperand, want[o.Name])
+		}
+	}
+}
+
+func TestParseShellSubstitutions(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string // the words of each command, commands of a pipeline joined by "|" and pipelines by ";"
+	}{
+		{"x=`date`; ls --bogus", "ls --bogus"},
+		{"echo `date` `id -u` | wc", "echo `date` `id -u`|wc"},
+		{"echo $((x+1)) | grep foo", "echo $((x+1))|grep foo"},
+		{"echo $(cd a && (ls)) ; ls -l", "echo $(cd a && (ls));ls -l"},
+		{"echo ${x:-$(id)} | wc", "echo ${x:-$(id)}|wc"},
+	}
+	for _, test := range tests {
+		var pipelines []string
+		for _, p := range parseShell(test.src) {
+			var cmds []string
+			for _, cmd := range p.Commands {
+				var words []string
+				for _, w := range cmd.Words {
+					words = append(words, w.Text)
+				}
+				cmds = append(cmds, strings.Join(words, " "))
+			}
+			pipelines = append(pipelines, strings.Join(cmds, "|"))
+		}
+		if got := strings.Join(pipelines, ";"); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The names the POSIX shell command language gives special meaning to.
+// They are documented in the shell command language page rather than
+// in pages of their own.
+var (
+	reservedWords = []string{
+		"!", "{", "}", "case", "do", "done", "elif", "else", "esac", "fi",
+		"for", "if", "in", "then", "until", "while",
+	}
+	specialBuiltins = []string{
+		"break", ":", ".", "continue", "eval", "exec", "exit", "export",
+		"readonly", "return", "set", "shift", "times", "trap", "unset",
+	}
+	regularBuiltins = []string{
+		"alias", "bg", "cd", "command", "false", "fc", "fg", "getopts",
+		"hash", "jobs", "kill", "newgrp", "pwd", "read", "true", "type",
+		"ulimit", "umask", "unalias", "wait",
+	}
+)
+
+// builtinNames are the names POSIX uses for the built-ins and reserved
+// words that aren't words, as in "colon — Null utility". They also name
+// the defs, whose paths can't have "." or "!" as a component.
+var builtinNames = map[string]string{
+	":": "colon", ".": "dot", "!": "bang", "{": "lbrace", "}": "rbrace",
+}
+
+// shellChapter matches the headings of the parts of the shell command
+// language page that document reserved words, built-ins and parameters,
+// such as "RESERVED WORDS" or "2.14 Special Built-In Utilities".
+var shellChapter = regexp.MustCompile(`(?mi)^[ \t]*(?:[0-9.]+[ \t]+)?(reserved words|special built-in utilities|regular built-in utilities|special parameters|shell variables)[ \t]*$`)
+
+// A shellWord is a reserved word or built-in utility documented in the
+// shell command language page.
+type shellWord struct {
+	Name    string
+	Kind    string // "builtin" or "keyword"
+	Keyword string // "special builtin", "builtin" or "keyword"
+	Doc     string
+
+	// Start and End are the byte offsets of Name in the page text.
+	Start, End int
+}
+
+// shellWords returns the reserved words and built-in utilities that p
+// documents, if it is the shell command language page. Each is located
+// at its "name — summary" heading or, in parts of the page that merely
+// list them, at its first mention.
+func (p *manPage) shellWords() []*shellWord {
+	var words []*shellWord
+	for _, c := range p.chapters(shellChapter) {
+		start, end := c.Start, c.End
+		var names []string
+		kind, keyword := "builtin", "builtin"
+		switch c.Title {
+		case "reserved words":
+			names, kind, keyword = reservedWords, "keyword", "keyword"
+		case "special built-in utilities":
+			names, keyword = specialBuiltins, "special builtin"
+		case "regular built-in utilities":
+			names = regularBuiltins
+		default:
+			continue
+		}
+		var found []*shellWord
+		for _, name := range names {
+			if w := shellWordHeading(p.Text, start, end, name); w != nil {
+				found = append(found, w)
+			}
+		}
+		if found == nil {
+			for _, name := range names {
+				if w := shellWordMention(p.Text, start, end, name); w != nil {
+					found = append(found, w)
+				}
+			}
+		}
+		for _, w := range found {
+			w.Kind, w.Keyword = kind, keyword
+		}
+		words = append(words, found...)
+	}
+	return words
+}
+
+// shellWordHeading locates the heading of name in text[start:end], of
+// the form "break — Exit from for, while, or until loop", from which it
+// takes the doc. It returns nil if there is none.
+func shellWordHeading(text string, start, end int, name string) *shellWord {
+	spelled := name
+	if alt, ok := builtinNames[name]; ok {
+		spelled = alt
+	}
+	for _, l := range splitLines(text[start:end], start) {
+		fields := strings.Fields(l.Text)
+		if len(fields) < 3 || (fields[0] != name && fields[0] != spelled) || (fields[1] != "—" && fields[1] != "-") {
+			continue
+		}
+		i := l.Offset + strings.Index(l.Text, fields[0])
+		return &shellWord{
+			Name:  name,
+			Doc:   strings.Join(fields[2:], " "),
+			Start: i,
+			End:   i + len(fields[0]),
+		}
+	}
+	return nil
+}
+
+// shellWordMention locates the first mention of name as a whole word in
+// text[start:end], or returns nil.
+func shellWordMention(text string, start, end int, name string) *shellWord {
+	for i := start; i < end; {
+		j := strings.Index(text[i:end], name)
+		if j < 0 {
+			return nil
+		}
+		j += i
+		if (j == start || isSpace(text[j-1])) && (j+len(name) == end || isSpace(text[j+len(name)])) {
+			return &shellWord{Name: name, Start: j, End: j + len(name)}
+		}
+		i = j + len(name)
+	}
+	return nil
+}
+
+// isSpace reports whether c is an ASCII space, tab or newline.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// shellWordPath returns the component naming w in def paths.
+func shellWordPath(w *shellWord) string {
+	if alt, ok := builtinNames[w.Name]; ok {
+		return alt
+	}
+	return w.Name
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 )
@@ -12,6 +13,15 @@ var (
 	cwd        = getCWD()
 )
 
+// passthroughCommands take the options of other commands as arguments,
+// as in "lookup ls -l", so options after their first argument are passed
+// on rather than parsed. Other commands parse options anywhere, as in
+// "lint a.sh --json".
+var passthroughCommands = map[string]bool{
+	"lookup":  true,
+	"explain": true,
+}
+
 func init() {
 	flagParser.LongDescription = "srclib-man extracts defs of POSIX commands for srclib-bash."
 }
@@ -26,6 +36,14 @@ func getCWD() string {
 
 func main() {
 	log.SetFlags(0)
+	for _, arg := range os.Args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			if passthroughCommands[arg] {
+				flagParser.Options |= flags.PassAfterNonOption
+			}
+			break
+		}
+	}
 	if _, err := flagParser.Parse(); err != nil {
 		os.Exit(1)
 	}
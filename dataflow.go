@@ -0,0 +1,101 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A Dataflow summarizes how a command uses its standard input and
+// output and the files it writes, from the STDIN, INPUT FILES, STDOUT
+// and OUTPUT FILES sections of its page.
+type Dataflow struct {
+	// Stdin is set if the command reads data from its standard input,
+	// and StdinDefault if it does so only when no file operands are
+	// given or one is "-", as cat does. Reading answers to prompts, as
+	// rm -i does, doesn't count.
+	Stdin        bool `json:",omitempty"`
+	StdinDefault bool `json:",omitempty"`
+
+	// Input is the kind of data read: "text", "binary" or "any", or ""
+	// if the page doesn't tell.
+	Input string `json:",omitempty"`
+
+	// Stdout is set if the command writes to its standard output, and
+	// Output is the kind of data written: "text", "binary" or "input"
+	// for a copy or transformation of the input, as with cat or sort.
+	Stdout bool   `json:",omitempty"`
+	Output string `json:",omitempty"`
+
+	// WritesFiles is set if the command writes files other than its
+	// standard output, and InPlace if it replaces or modifies its input
+	// files, as compress does.
+	WritesFiles bool `json:",omitempty"`
+	InPlace     bool `json:",omitempty"`
+}
+
+var (
+	// notUsed matches the sections saying that a stream or file isn't
+	// used at all: "Not used." or "None.".
+	notUsed = regexp.MustCompile(`^(not used|none)\b`)
+
+	// anyFileType matches the phrases allowing input of any kind: "any
+	// file type", "any type of file" or "of any type".
+	anyFileType = regexp.MustCompile(`\bany (file )?type\b`)
+
+	// copiedInput matches the phrases describing output made from the
+	// input itself, as in "the sorted input" or "bytes read from the
+	// input files", rather than about it, as in "for each input file".
+	copiedInput = regexp.MustCompile(`\b(the|sorted) input\b`)
+)
+
+// dataflow returns the summary of the STDIN, INPUT FILES, STDOUT and
+// OUTPUT FILES sections of p, or nil if p has neither a STDIN nor a
+// STDOUT section, as pages other than the POSIX ones don't.
+func (p *manPage) dataflow() *Dataflow {
+	if p.section("STDIN") == nil && p.section("STDOUT") == nil {
+		return nil
+	}
+	text := func(title string) string {
+		_, s := stripMarginCodes(strings.Join(strings.Fields(p.body(title)), " "))
+		return strings.ToLower(normalizeDashes(strings.TrimSpace(s)))
+	}
+	stdin, input, stdout, output := text("STDIN"), text("INPUT FILES"), text("STDOUT"), text("OUTPUT FILES")
+
+	d := &Dataflow{}
+	noOperands := strings.Contains(stdin, "no file operand")
+	if stdin != "" && !notUsed.MatchString(stdin) && (noOperands || !strings.Contains(stdin, "prompt")) {
+		d.Stdin, d.StdinDefault = true, noOperands
+	}
+	switch in := stdin + " " + input; {
+	case strings.Contains(in, "text file"):
+		d.Input = "text"
+	case anyFileType.MatchString(in):
+		d.Input = "any"
+	case strings.Contains(in, "binary"):
+		d.Input = "binary"
+	}
+
+	if stdout != "" && !notUsed.MatchString(stdout) {
+		d.Stdout = true
+		switch {
+		case strings.Contains(stdout, "binary") || strings.Contains(stdout, "compressed data"):
+			d.Output = "binary"
+		case copiedInput.MatchString(stdout):
+			d.Output = "input"
+		default:
+			// Other output is described by its format, as with wc's
+			// "%d %d %d %s\n".
+			d.Output = "text"
+		}
+	}
+
+	if output != "" && !notUsed.MatchString(output) {
+		d.WritesFiles = true
+		for _, s := range []string{"replaced", "in place", "modif", "overwrit"} {
+			if strings.Contains(output, s) {
+				d.InPlace = true
+			}
+		}
+	}
+	return d
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+)
+
+// patternUtilities are the utilities whose patterns are in one dialect
+// unless their pages or options say otherwise: sed's and grep's are
+// BREs, awk's are EREs and find's -name takes shell patterns.
+var patternUtilities = map[string]string{
+	"grep": "BRE", "egrep": "ERE", "fgrep": "fixed", "sed": "BRE",
+	"expr": "BRE", "awk": "ERE", "gawk": "ERE", "mawk": "ERE", "nawk": "ERE",
+	"find": "glob",
+}
+
+// globPhrases name shell patterns in the docs of pattern arguments, as
+// in "the pattern matching notation" of POSIX find or "shell PATTERN"
+// of GNU ls.
+var globPhrases = []string{"pattern matching notation", "shell pattern", "wildcard", "glob"}
+
+// dialectSwitches are the phrases naming the dialect in the docs of
+// options that change it, such as grep's "-E  Match using extended
+// regular expressions", most specific first.
+var dialectSwitches = []struct{ phrase, dialect string }{
+	{"perl", "PCRE"},
+	{"fixed string", "fixed"},
+	{"extended regular expression", "ERE"},
+	{"basic regular expression", "BRE"},
+}
+
+// patternDialect returns the dialect of the patterns taken by an
+// option-argument or operand of command whose placeholder, type (as
+// told by argType) and doc are given: "BRE", "ERE" or "glob", or "" if
+// it takes none. The scripts of sed, whose addresses and s commands
+// hold BREs, count as patterns.
+func patternDialect(command, placeholder, typ, doc string) string {
+	switch {
+	case typ == "BRE" || typ == "ERE":
+		return typ
+	case command == "sed" && typ == "" && strings.Contains(strings.ToLower(placeholder), "script"):
+		return "BRE"
+	case typ != "pattern":
+		return ""
+	}
+	doc = strings.ToLower(strings.Join(strings.Fields(doc), " "))
+	for _, p := range globPhrases {
+		if strings.Contains(doc, p) {
+			return "glob"
+		}
+	}
+	dialect := patternUtilities[command]
+	// GNU find's -iregex is documented as "Like -regex, but ...".
+	regex := strings.Contains(doc, "regular expression") || strings.Contains(doc, "regex")
+	if regex && dialect != "BRE" && dialect != "ERE" {
+		return "BRE"
+	}
+	if dialect == "fixed" {
+		return ""
+	}
+	return dialect
+}
+
+// dialectSwitch returns the dialect that an option without an argument
+// selects for the command's patterns, as grep's -E selects "ERE" and -F
+// "fixed", or "" if it selects none. Only the first sentence of its doc
+// is searched, as others may mention the dialects in passing.
+func dialectSwitch(o *pageOption) string {
+	if o.Arg != "" {
+		return ""
+	}
+	first := strings.Join(strings.Fields(o.Doc), " ")
+	if s := splitSentences(first); len(s) > 0 {
+		first = s[0]
+	}
+	first = strings.ToLower(first)
+	for _, d := range dialectSwitches {
+		if strings.Contains(first, d.phrase) {
+			return d.dialect
+		}
+	}
+	return ""
+}
@@ -0,0 +1,244 @@
+package main
+
+import "strings"
+
+// A pageEntry is a tagged paragraph in a section of a man page, such as
+// an option or operand followed by its indented description.
+type pageEntry struct {
+	Tag string
+	Doc string
+
+	// TagStart and TagEnd are the byte offsets of Tag in the page text;
+	// DocEnd is the offset just past the last line of Doc.
+	TagStart, TagEnd int
+	DocEnd           int
+
+	// Groups are the POSIX option groups the entry is marked with, such
+	// as "XSI"; the margin codes are stripped from Tag and Doc.
+	Groups []string
+}
+
+// A pageLine is a line of a page together with its byte offset in the
+// page text and its indentation in columns.
+type pageLine struct {
+	Text   string
+	Offset int
+	Indent int
+}
+
+func (l pageLine) blank() bool { return strings.TrimSpace(l.Text) == "" }
+
+// lines splits the body of the section with the given title into lines.
+func (p *manPage) lines(title string) []pageLine {
+	s := p.section(title)
+	if s == nil {
+		return nil
+	}
+	return splitLines(p.Text[s.Start:s.End], s.Start)
+}
+
+// splitLines splits text, which starts at offset in the page text, into
+// lines.
+func splitLines(text string, offset int) []pageLine {
+	var lines []pageLine
+	for _, l := range strings.SplitAfter(text, "\n") {
+		line := strings.TrimRight(l, "\n")
+		lines = append(lines, pageLine{
+			Text:   line,
+			Offset: offset,
+			Indent: len(line) - len(strings.TrimLeft(line, " ")),
+		})
+		offset += len(l)
+	}
+	return lines
+}
+
+// entries returns the tagged paragraphs in the section with the given
+// title. A tagged paragraph starts at the section's base indentation
+// and continues with lines indented to the description column; short
+// tags share their first line with the description, as in
+//
+//	−u        Write bytes from the input file to the standard output
+//	          without delay as each is read.
+//
+// Untagged prose at the base indentation may be returned as well, so
+// callers should check that the tags look like what they expect.
+func (p *manPage) entries(title string) []*pageEntry {
+	return p.entriesIn(p.lines(title))
+}
+
+// entriesIn returns the tagged paragraphs in lines of p, as entries
+// does.
+func (p *manPage) entriesIn(lines []pageLine) []*pageEntry {
+	base, col := entryColumns(lines)
+	if base < 0 {
+		return nil
+	}
+
+	var entries []*pageEntry
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if l.blank() || l.Indent != base {
+			continue
+		}
+
+		e := &pageEntry{}
+		tag := l.Text[base:]
+		var doc []string
+		// The split must not cut a synopsis such as "gsub(ere, repl[,
+		// in])" that merely has a space before the column.
+		if split := columnOffset(l.Text, col); !p.Roff && split > 0 && split < len(l.Text) && l.Text[split-1] == ' ' && l.Text[split] != ' ' && balanced(l.Text[base:split]) {
+			tag = l.Text[base:split]
+			doc = append(doc, l.Text[split:])
+		}
+		tag = strings.TrimRight(tag, " ")
+		e.TagStart = l.Offset + base
+		if codes, rest := stripMarginCodes(tag); rest != tag {
+			e.Groups = codes
+			if i := strings.Index(tag, rest); i > 0 {
+				e.TagStart += i
+			}
+			tag = rest
+		}
+		e.Tag = tag
+		e.TagEnd = e.TagStart + len(tag)
+		e.DocEnd = l.Offset + len(l.Text)
+
+		// Gather the continuation lines, including paragraphs separated
+		// by blank lines, that are indented to the description column.
+		sawBlank := false
+		for j := i + 1; j < len(lines); j++ {
+			if lines[j].blank() {
+				sawBlank = true
+				continue
+			}
+			if lines[j].Indent < col {
+				break
+			}
+			if sawBlank && len(doc) > 0 {
+				doc = append(doc, "")
+			}
+			sawBlank = false
+			doc = append(doc, lines[j].Text)
+			e.DocEnd = lines[j].Offset + len(lines[j].Text)
+			i = j
+		}
+		if len(doc) == 0 {
+			continue
+		}
+		codes, text := stripMarginCodes(joinLines(doc))
+		e.Doc = text
+		for _, c := range codes {
+			if !contains(e.Groups, c) {
+				e.Groups = append(e.Groups, c)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// subsectionAt returns the title of the subsection of lines containing
+// the byte offset, which is the last line before it indented by 3
+// columns or less, or title if there is none.
+func subsectionAt(lines []pageLine, offset int, title string) string {
+	for _, l := range lines {
+		if l.Offset > offset {
+			break
+		}
+		if !l.blank() && l.Indent <= 3 {
+			title = strings.TrimSpace(l.Text)
+		}
+	}
+	return title
+}
+
+// entryColumns returns the base indentation of lines and the column at
+// which the descriptions of tagged paragraphs start, which is the most
+// common deeper indentation. Subsection headings, which man indents by
+// 3 columns, are not taken into account. It returns -1 for an empty
+// section.
+func entryColumns(lines []pageLine) (base, col int) {
+	base = -1
+	for _, l := range lines {
+		if !l.blank() && l.Indent > 3 && (base < 0 || l.Indent < base) {
+			base = l.Indent
+		}
+	}
+	if base < 0 {
+		return -1, 0
+	}
+	counts := map[int]int{}
+	for _, l := range lines {
+		if !l.blank() && l.Indent > base {
+			counts[l.Indent]++
+		}
+	}
+	col = base + 10
+	for indent, n := range counts {
+		if n > counts[col] || (n == counts[col] && indent < col) {
+			col = indent
+		}
+	}
+	return base, col
+}
+
+// columnOffset returns the byte offset in line of the given column,
+// counting each rune as one column, or -1 if line is shorter.
+func columnOffset(line string, col int) int {
+	for i := range line {
+		if col == 0 {
+			return i
+		}
+		col--
+	}
+	return -1
+}
+
+// balanced reports whether the parentheses and brackets in s are
+// balanced.
+func balanced(s string) bool {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		}
+	}
+	return depth == 0
+}
+
+// joinLines joins wrapped lines into paragraphs, undoing the hyphenation
+// man inserts when it breaks words across lines. Empty strings in lines
+// separate paragraphs.
+func joinLines(lines []string) string {
+	var b strings.Builder
+	sep := ""
+	for _, l := range lines {
+		l = strings.Join(strings.Fields(l), " ")
+		if l == "" {
+			sep = "\n\n"
+			continue
+		}
+		b.WriteString(sep)
+		if strings.HasSuffix(l, "‐") {
+			b.WriteString(strings.TrimSuffix(l, "‐"))
+			sep = ""
+		} else {
+			b.WriteString(l)
+			sep = " "
+		}
+	}
+	return b.String()
+}
+
+// normalizeDashes replaces the minus signs and hyphens that man pages
+// render options with by ASCII hyphen-minus.
+func normalizeDashes(s string) string {
+	if !strings.ContainsAny(s, "−‐") {
+		return s
+	}
+	return strings.NewReplacer("−", "-", "‐", "-").Replace(s)
+}
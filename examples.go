@@ -0,0 +1,210 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// An Example is the Data of an "example" annotation: a shell snippet
+// from the EXAMPLES section of a page and the prose introducing it.
+type Example struct {
+	Text string // the snippet, without indentation or prompts
+	Doc  string `json:",omitempty"`
+}
+
+// A pageExample is a shell snippet in the EXAMPLES section of a page.
+type pageExample struct {
+	Example
+	File string // the page file
+
+	// Rendered is set for examples of rendered pages, whose offsets
+	// locate nothing in File.
+	Rendered bool
+
+	// StartLine and EndLine are the 1-based lines the snippet spans, and
+	// Start and End its byte offsets, in the page text.
+	StartLine, EndLine int
+	Start, End         int
+
+	// src is the page text of the snippet with its prompts blanked out
+	// and its dashes normalized, and offsets maps the byte offsets in src
+	// to those in the page text.
+	src     string
+	offsets []int
+}
+
+var (
+	// examplePrompt matches the shell prompt that GNU pages start the
+	// commands of their examples with, as in "$ find /tmp -name core".
+	examplePrompt = regexp.MustCompile(`^\s*\$ `)
+
+	// listMarker matches the markers of numbered examples, as in POSIX's
+	// "1. The following command:".
+	listMarker = regexp.MustCompile(`^\d+\.\s+`)
+)
+
+// examples returns the shell snippets in the EXAMPLES section of p. On
+// pages that start commands with a "$ " prompt, as GNU pages do, the
+// snippets are the prompted lines and the lines they continue onto with
+// a trailing backslash; the output following them is not included. On
+// other pages, as in POSIX, they are the blocks of lines set off by a
+// blank line and indented deeper than the prose before them.
+func (p *manPage) examples() []*pageExample {
+	lines := p.lines("EXAMPLES")
+	prompted := false
+	for _, l := range lines {
+		if examplePrompt.MatchString(l.Text) {
+			prompted = true
+			break
+		}
+	}
+
+	var examples []*pageExample
+	var para, lastPara []string
+	proseIndent := -1
+	blank := true
+	for i := 0; i < len(lines); {
+		l := lines[i]
+		if l.blank() {
+			if len(para) > 0 {
+				lastPara, para = para, nil
+			}
+			blank = true
+			i++
+			continue
+		}
+
+		n := 0
+		switch {
+		case prompted && examplePrompt.MatchString(l.Text):
+			n = 1
+			for i+n < len(lines) && strings.HasSuffix(strings.TrimRight(lines[i+n-1].Text, " \t"), "\\") {
+				n++
+			}
+		case !prompted && blank && proseIndent >= 0 && l.Indent > proseIndent:
+			for i+n < len(lines) && !lines[i+n].blank() && lines[i+n].Indent >= l.Indent {
+				n++
+			}
+		}
+		blank = false
+		if n == 0 {
+			text := strings.TrimSpace(l.Text)
+			marker := listMarker.FindString(text)
+			proseIndent = l.Indent + len(marker)
+			para = append(para, text[len(marker):])
+			i++
+			continue
+		}
+
+		// Snippets in a row share the prose introducing them, as in
+		// "Both of the following commands print ...".
+		doc := para
+		if len(doc) == 0 {
+			doc = lastPara
+		}
+		e := newPageExample(p.Text, lines[i:i+n], l.Indent, prompted)
+		e.File = p.File
+		e.Rendered = p.Rendered
+		e.Doc = normalizeDashes(strings.Join(strings.Fields(strings.Join(doc, " ")), " "))
+		examples = append(examples, e)
+		para, lastPara = nil, doc
+		i += n
+	}
+	return examples
+}
+
+// newPageExample returns the example made of lines of the page text,
+// dedenting them by indent and removing their prompts if prompted.
+func newPageExample(text string, lines []pageLine, indent int, prompted bool) *pageExample {
+	first, last := lines[0], lines[len(lines)-1]
+	e := &pageExample{
+		StartLine: strings.Count(text[:first.Offset], "\n") + 1,
+		Start:     first.Offset,
+		End:       last.Offset + len(last.Text),
+	}
+	e.EndLine = e.StartLine + len(lines) - 1
+
+	var snippet []string
+	var src []byte
+	for _, l := range lines {
+		line := l.Text
+		code := line
+		if len(code) >= indent && strings.TrimSpace(code[:indent]) == "" {
+			code = code[indent:]
+		} else {
+			code = strings.TrimLeft(code, " \t")
+		}
+		if prompted {
+			if loc := examplePrompt.FindStringIndex(line); loc != nil {
+				code = strings.TrimPrefix(strings.TrimLeft(code, " \t"), "$ ")
+				line = strings.Repeat(" ", loc[1]) + line[loc[1]:]
+			}
+		}
+		snippet = append(snippet, normalizeDashes(code))
+
+		// Normalizing the dashes shortens the line, so the offsets of
+		// the bytes of src are kept.
+		for i := 0; i <= len(line); {
+			if i == len(line) {
+				src = append(src, '\n')
+				e.offsets = append(e.offsets, l.Offset+i)
+				break
+			}
+			if r := line[i:]; strings.HasPrefix(r, "−") || strings.HasPrefix(r, "‐") {
+				src = append(src, '-')
+				e.offsets = append(e.offsets, l.Offset+i)
+				i += len("−")
+				continue
+			}
+			src = append(src, line[i])
+			e.offsets = append(e.offsets, l.Offset+i)
+			i++
+		}
+	}
+	e.Text = strings.Join(snippet, "\n")
+	e.src = string(src)
+	return e
+}
+
+// offset returns the byte offset in the page text of the byte at offset
+// i in the example's src.
+func (e *pageExample) offset(i int) int {
+	if i >= len(e.offsets) {
+		return e.End
+	}
+	return e.offsets[i]
+}
+
+// exampleRefs returns the refs from the commands, options and operands
+// used in the example e to their defs. The refs of rendered pages have no
+// offsets, as with clearLocations.
+func exampleRefs(index *defIndex, u *unit.SourceUnit, e *pageExample) []*graph.Ref {
+	var refs []*graph.Ref
+	for _, p := range parseShell(e.src) {
+		for _, cmd := range p.Commands {
+			for _, b := range bindCommand(index, cmd.Words) {
+				if b.Def == nil || b.Kind == "argument" {
+					continue
+				}
+				start, end := e.offset(b.Start), e.offset(b.End)
+				if e.Rendered {
+					start, end = 0, 0
+				}
+				refs = append(refs, &graph.Ref{
+					DefUnitType: b.Def.UnitType,
+					DefUnit:     b.Def.Unit,
+					DefPath:     b.Def.Path,
+					UnitType:    u.Type,
+					Unit:        u.Name,
+					File:        e.File,
+					Start:       uint32(start),
+					End:         uint32(end),
+				})
+			}
+		}
+	}
+	return refs
+}
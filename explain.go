@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func init() {
+	_, err := flagParser.AddCommand("explain",
+		"explain a shell command line",
+		"Annotate each word of a shell command line with the documentation of the command, option or operand it refers to, reporting unknown options.",
+		&explainCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type ExplainCmd struct {
+	graphSource
+}
+
+var explainCmd ExplainCmd
+
+func (c *ExplainCmd) Usage() string {
+	return "[explain-OPTIONS] COMMAND-LINE"
+}
+
+func (c *ExplainCmd) Execute(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("explain needs a command line")
+	}
+	line := strings.Join(args, " ")
+
+	index, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	var unknown []string
+	for _, p := range parseShell(line) {
+		for _, cmd := range p.Commands {
+			bindings := bindCommand(index, cmd.Words)
+			first, last := cmd.Words[0], cmd.Words[len(cmd.Words)-1]
+			fmt.Fprintf(w, "%s\n\n", line[first.Start:last.End])
+			for _, row := range explainRows(bindings) {
+				label := row.label
+				if row.nested {
+					label = "  " + label
+				}
+				fmt.Fprintf(w, "  %-20s %s\n", label, row.doc)
+				if row.unknown {
+					unknown = append(unknown, row.label)
+				}
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// An explainRow is a line of explain's output: a command, option (with
+// its argument, if any) or operand and the first paragraph of its
+// documentation.
+type explainRow struct {
+	label   string
+	doc     string
+	unknown bool
+	nested  bool // whether the row is of a nested command
+}
+
+// explainRows returns the rows explaining bindings. The rows of nested
+// commands, as in "xargs rm -f", are indented under the outer command.
+func explainRows(bindings []*binding) []*explainRow {
+	var rows []*explainRow
+	for _, b := range bindings {
+		add := func(row *explainRow) {
+			row.nested = b.Command != bindings[0]
+			rows = append(rows, row)
+		}
+		switch b.Kind {
+		case "argument":
+			if len(rows) > 0 {
+				rows[len(rows)-1].label += " " + b.Text
+			}
+			continue
+		case "unknown":
+			what := "unknown option"
+			if b.Command == b {
+				what = "no man page found"
+			}
+			add(&explainRow{label: b.Text, doc: what, unknown: true})
+			continue
+		case "end-of-options":
+			add(&explainRow{label: b.Text, doc: "end of options"})
+			continue
+		case "terminator":
+			add(&explainRow{label: b.Text, doc: "end of " + b.Def.Name + " command"})
+			continue
+		}
+		doc := ""
+		if b.Def != nil {
+			doc = firstParagraph(defDoc(b.Def))
+			if (b.Kind == "operand" || b.Kind == "signal") && b.Def.Name != b.Text {
+				doc = b.Def.Name + ": " + doc
+			}
+		}
+		add(&explainRow{label: b.Text, doc: doc})
+	}
+	return rows
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// expressionUtilities are the utilities whose operands form a small
+// expression language, mapped to the kind of def their primaries get.
+var expressionUtilities = map[string]string{
+	"find": "primary",
+	"test": "operator",
+	"expr": "operator",
+}
+
+// findOperators are the operators of find's expressions, which combine
+// primaries rather than test files.
+var findOperators = map[string]bool{
+	"!": true, "(": true, ")": true, ",": true, "-a": true, "-o": true,
+	"-and": true, "-or": true, "-not": true,
+}
+
+// expressionSections are the sections documenting the primaries and
+// operators: find's page has them in DESCRIPTION (POSIX) or EXPRESSION
+// (GNU), test's in OPERANDS and expr's in a table in EXTENDED
+// DESCRIPTION.
+var expressionSections = []string{"DESCRIPTION", "OPERANDS", "EXTENDED DESCRIPTION", "EXPRESSION"}
+
+// exprOperator matches the words of an expression tag that are operators
+// or primaries rather than placeholders: "-name", "-eq", "=", "!=", "|"
+// or "(".
+var exprOperator = regexp.MustCompile(`^(?:-[A-Za-z][A-Za-z0-9]*|[!=<>|&:+*/%(),;-]+)$`)
+
+// An exprTerm is a primary or operator of an expression language, such
+// as find's "-name pattern" or test's "n1 -eq n2".
+type exprTerm struct {
+	*pageOption
+	Kind string // "primary" or "operator"
+}
+
+// expressions returns the primaries and operators that p documents, if
+// it is the page of a utility with an expression language. The Arg of
+// each is the rest of its tag, e.g. "pattern" for "-name pattern" or
+// "n2" for "n1 -eq n2". Terms documented in several forms, like GNU
+// find's "-perm -mode" and "-perm /mode", are defined by the first.
+func (p *manPage) expressions() []*exprTerm {
+	kind, ok := expressionUtilities[p.Name]
+	if !ok {
+		return nil
+	}
+	var terms []*exprTerm
+	seen := map[string]bool{}
+	for _, title := range expressionSections {
+		entries := p.entries(title)
+		entries = append(entries, tableEntries(p.lines(title))...)
+		for _, e := range entries {
+			o := parseExprTag(e)
+			if o == nil || seen[o.Name] {
+				continue
+			}
+			k := kind
+			if p.Name == "find" {
+				// Other entries, such as the "-n" of numeric arguments
+				// or the "%%" directive of -printf, are not primaries.
+				if findOperators[o.Name] {
+					k = "operator"
+				} else if len(o.Name) < 3 || o.Name[0] != '-' {
+					continue
+				}
+			}
+			seen[o.Name] = true
+			terms = append(terms, &exprTerm{pageOption: o, Kind: k})
+		}
+	}
+	for _, t := range terms {
+		for _, title := range expressionSections {
+			addTerminators(t.pageOption, p.lines(title))
+		}
+	}
+	return terms
+}
+
+// parseExprTag returns the primary or operator named in the tag of e,
+// or nil if the tag names none, as for "Tests" or "string".
+func parseExprTag(e *pageEntry) *pageOption {
+	fields := strings.Fields(normalizeDashes(e.Tag))
+	op, bracketed := -1, false
+	for i, f := range fields {
+		// Optional operators are bracketed, as in find's
+		// "expression [-a] expression".
+		if f != "[" && strings.HasPrefix(f, "[") && strings.HasSuffix(f, "]") {
+			f, bracketed = f[1:len(f)-1], true
+		}
+		if exprOperator.MatchString(f) {
+			op, fields[i] = i, f
+			break
+		}
+		bracketed = false
+	}
+	// GNU expr names some operators with words, as in "match STRING
+	// REGEXP", whose placeholders are in upper case.
+	if op < 0 && len(fields) > 1 && isLower(fields[0]) {
+		op = 0
+		for _, f := range fields[1:] {
+			if !isUpper(f) {
+				op = -1
+			}
+		}
+	}
+	if op < 0 {
+		return nil
+	}
+
+	start := fieldOffset(e.Tag, op)
+	end := start + len(strings.Fields(e.Tag[start:])[0])
+	if bracketed {
+		start, end = start+1, end-1
+	}
+	return &pageOption{
+		Name:   fields[op],
+		Arg:    strings.Join(fields[op+1:], " "),
+		Doc:    e.Doc,
+		Groups: e.Groups,
+		Start:  e.TagStart + start,
+		End:    e.TagStart + end,
+	}
+}
+
+// fieldOffset returns the byte offset of the k-th space-separated field
+// of s.
+func fieldOffset(s string, k int) int {
+	offset := 0
+	for i := 0; ; i++ {
+		rest := s[offset:]
+		offset += len(rest) - len(strings.TrimLeft(rest, " \t"))
+		if i == k {
+			return offset
+		}
+		if j := strings.IndexAny(s[offset:], " \t"); j >= 0 {
+			offset += j
+		} else {
+			return len(s)
+		}
+	}
+}
+
+func isLower(s string) bool {
+	return s == strings.ToLower(s) && strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
+
+func isUpper(s string) bool {
+	return s == strings.ToUpper(s) && strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
+
+// tableEntries returns the rows of the boxed tables in lines as entries,
+// taking the first cell as the tag and the second as the doc, as in
+//
+//	│expr1 | expr2 │ Returns the evaluation of expr1 if │
+//	│              │ it is neither null nor zero.       │
+//
+// Rows whose first cell is empty continue the doc of the previous row.
+func tableEntries(lines []pageLine) []*pageEntry {
+	var entries []*pageEntry
+	var e *pageEntry
+	var doc []string
+	flush := func() {
+		if e != nil && len(doc) > 0 {
+			e.Doc = joinLines(doc)
+			entries = append(entries, e)
+		}
+		e, doc = nil, nil
+	}
+	for _, l := range lines {
+		text := strings.TrimSpace(l.Text)
+		if !strings.HasPrefix(text, "│") {
+			flush()
+			continue
+		}
+		cells := strings.Split(text, "│")
+		if len(cells) < 4 {
+			continue
+		}
+		tag, desc := strings.TrimSpace(cells[1]), strings.TrimSpace(cells[2])
+		if tag != "" {
+			flush()
+			start := l.Offset + strings.Index(l.Text, cells[1]) + strings.Index(cells[1], tag)
+			e = &pageEntry{Tag: tag, TagStart: start, TagEnd: start + len(tag)}
+		}
+		if e != nil && desc != "" {
+			doc = append(doc, desc)
+			e.DocEnd = l.Offset + len(l.Text)
+		}
+	}
+	flush()
+	return entries
+}
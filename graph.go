@@ -8,10 +8,10 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"regexp"
 	"strings"
 
+	"sourcegraph.com/sourcegraph/srclib/ann"
 	"sourcegraph.com/sourcegraph/srclib/graph"
 	"sourcegraph.com/sourcegraph/srclib/unit"
 )
@@ -47,75 +47,334 @@ type GraphCmd struct{}
 var graphCmd GraphCmd
 
 func (c *GraphCmd) Execute(args []string) error {
+	units, err := readUnits()
+	if err != nil {
+		return err
+	}
+
+	out, err := graphUnits(units)
+	if err != nil {
+		return fmt.Errorf("Failed to graph source units: %s", err)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+		return fmt.Errorf("Failed to output graph data: %s", err)
+	}
+	return nil
+}
+
+// readUnits reads the source units to graph from STDIN.
+func readUnits() (unit.SourceUnits, error) {
 	inputBytes, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
-		return fmt.Errorf("Failed to read STDIN: %s", err)
+		return nil, fmt.Errorf("Failed to read STDIN: %s", err)
 	}
 	var units unit.SourceUnits
 	if err := json.NewDecoder(bytes.NewReader(inputBytes)).Decode(&units); err != nil {
 		// Legacy API: try parsing input as a single source unit
 		var u *unit.SourceUnit
 		if err := json.NewDecoder(bytes.NewReader(inputBytes)).Decode(&u); err != nil {
-			return fmt.Errorf("Failed to parse source units from input: %s", err)
+			return nil, fmt.Errorf("Failed to parse source units from input: %s", err)
 		}
 		units = unit.SourceUnits{u}
 	}
 	if err := os.Stdin.Close(); err != nil {
-		return fmt.Errorf("Failed to close STDIN: %s", err)
+		return nil, fmt.Errorf("Failed to close STDIN: %s", err)
 	}
 
 	if len(units) == 0 {
 		log.Fatal("Input contains no source unit data.")
 	}
-
-	out, err := graphUnits(units)
-	if err != nil {
-		return fmt.Errorf("Failed to graph source units: %s", err)
-	}
-
-	if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
-		return fmt.Errorf("Failed to output graph data: %s", err)
-	}
-	return nil
+	return units, nil
 }
 
 func graphUnits(units unit.SourceUnits) (*graph.Output, error) {
 	output := graph.Output{}
 
 	for _, u := range units {
+		if u.Type != "ManPages" {
+			continue
+		}
+		first := len(output.Defs)
+		var examples []*pageExample
 		for _, f := range u.Files {
-			graphPage(f, &output)
+			// A page that fails to graph is skipped, along with the defs
+			// and refs made of it, rather than failing the others.
+			nDefs, nRefs, nAnns := len(output.Defs), len(output.Refs), len(output.Anns)
+			var err error
+			if u.Config["format"] == "whatis" {
+				err = graphWhatis(u, f, &output)
+			} else {
+				var e []*pageExample
+				e, err = graphPage(u, f, &output)
+				examples = append(examples, e...)
+			}
+			if err != nil {
+				log.Printf("Skipping %s: %s", f, err)
+				output.Defs, output.Refs, output.Anns = output.Defs[:nDefs], output.Refs[:nRefs], output.Anns[:nAnns]
+			}
+		}
+		linkVariables(&output, first)
+		output.Defs = append(output.Defs[:first], uniqueDefs(output.Defs[first:])...)
+
+		// Examples use the commands of other pages, as in "find . -print
+		// | xargs rm", so they are linked once all defs are known.
+		if len(examples) > 0 {
+			index := newDefIndex(&output)
+			for _, e := range examples {
+				output.Refs = append(output.Refs, exampleRefs(index, u, e)...)
+			}
 		}
 	}
+	linkTranslations(units, &output)
+	resolveSignalRefs(&output)
 
 	return &output, nil
 }
 
-func graphPage(page string, output *graph.Output) error {
-	f, err := os.Open(page)
+// linkTranslations links the commands of translated pages to the defs
+// of the canonical English pages, so that consumers can fall back to
+// them when a translation is missing. Commands whose canonical page was
+// not graphed are not linked.
+func linkTranslations(units unit.SourceUnits, output *graph.Output) {
+	defined := map[graph.DefKey]bool{}
+	for _, def := range output.Defs {
+		defined[def.DefKey] = true
+	}
+	translated := map[string]*unit.SourceUnit{}
+	for _, u := range units {
+		if u.Type == "ManPages" && u.Config["locale"] != "" {
+			translated[u.Name] = u
+		}
+	}
+	for _, def := range output.Defs {
+		u := translated[def.Unit]
+		if u == nil || def.Kind != "command" {
+			continue
+		}
+		canonical := u.Config["canonical"]
+		if canonical == "" {
+			canonical = "man"
+		}
+		target := graph.DefKey{
+			UnitType: "ManPages",
+			Unit:     canonical,
+			Path:     canonicalPage(def.File, u.Config["locale"]) + "/" + def.Name,
+		}
+		if !defined[target] {
+			continue
+		}
+		output.Refs = append(output.Refs, &graph.Ref{
+			DefUnitType: target.UnitType,
+			DefUnit:     target.Unit,
+			DefPath:     target.Path,
+			UnitType:    "ManPages",
+			Unit:        u.Name,
+			File:        def.File,
+			Start:       def.DefStart,
+			End:         def.DefEnd,
+		})
+	}
+}
+
+// uniqueDefs drops the defs whose path is already taken by an earlier
+// one, as happens with defs in namespaces shared by the pages of a unit,
+// such as the built-ins of awk documented by both awk(1) and awk(1p).
+func uniqueDefs(defs []*graph.Def) []*graph.Def {
+	seen := map[string]bool{}
+	var unique []*graph.Def
+	for _, def := range defs {
+		if !seen[def.Path] {
+			seen[def.Path] = true
+			unique = append(unique, def)
+		}
+	}
+	return unique
+}
+
+// graphPage graphs the page, returning the examples of its EXAMPLES
+// section for their commands to be linked to their defs once those of
+// the whole unit are known.
+func graphPage(u *unit.SourceUnit, page string, output *graph.Output) ([]*pageExample, error) {
+	p, err := readPage(page)
 	if err != nil {
-		return fmt.Errorf("Failed to open file %s: %s", page, err)
+		return nil, err
+	}
+	if p.Rendered {
+		defer clearLocations(output, len(output.Defs), len(output.Refs), len(output.Anns))
+	}
+
+	// Only the pages of command sections document commands: those of
+	// others, such as open(2), would shadow them. Any section may define
+	// signals, as signal(7) and signal.h(0p) do.
+	var examples []*pageExample
+	if isCommandSection(p.Section) {
+		if examples, err = graphCommand(u, page, p, output); err != nil {
+			return nil, err
+		}
 	}
-	defer f.Close()
 
-	_, file := filepath.Split(page)
-	dotIndex := strings.IndexRune(file, '.')
-	name := file[:dotIndex]
+	for _, s := range p.signals() {
+		sigDef, err := makeSignalDef(u.Name, page, s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create signal def: %s", err)
+		}
+		output.Defs = append(output.Defs, sigDef)
+	}
 
-	def, err := makeCommandDef(page, name, len(name))
+	// Link the signals named in ASYNCHRONOUS EVENTS, and by kill and trap,
+	// to their defs. The refs are dropped by resolveSignalRefs if the
+	// unit has no page defining the signals.
+	for _, m := range p.signalMentions() {
+		output.Refs = append(output.Refs, &graph.Ref{
+			DefUnitType: "ManPages",
+			DefUnit:     u.Name,
+			DefPath:     signalDefPath(m.Signal),
+			UnitType:    "ManPages",
+			Unit:        u.Name,
+			File:        page,
+			Start:       uint32(m.Start),
+			End:         uint32(m.End),
+		})
+	}
+
+	return examples, nil
+}
+
+// clearLocations zeroes the offsets and lines of the defs, refs and
+// annotations of output from the given indexes on, which were made of a
+// rendered page: they are positions in the rendered text, which srclib
+// would take for positions in the roff or compressed source file.
+func clearLocations(output *graph.Output, defs, refs, anns int) {
+	for _, def := range output.Defs[defs:] {
+		def.DefStart, def.DefEnd = 0, 0
+	}
+	for _, ref := range output.Refs[refs:] {
+		ref.Start, ref.End = 0, 0
+	}
+	for _, a := range output.Anns[anns:] {
+		a.StartLine, a.EndLine = 0, 0
+	}
+}
+
+// graphCommand graphs the command documented by the page p, with its
+// options, operands and the languages it defines, returning the examples
+// of its EXAMPLES section.
+func graphCommand(u *unit.SourceUnit, page string, p *manPage, output *graph.Output) ([]*pageExample, error) {
+	name := p.Name
+
+	opts := p.options()
+	optionNames := map[string]bool{}
+	for _, o := range opts {
+		optionNames[o.Name] = true
+	}
+	history, optionHistory := p.history(optionNames)
+
+	def, err := makeCommandDef(u.Name, page, p, history)
 	if err != nil {
-		return fmt.Errorf("failed to create command def: %s", err)
+		return nil, fmt.Errorf("failed to create command def: %s", err)
+	}
+	if _, summary := p.summary(); summary != "" {
+		def.Docs = []*graph.DefDoc{{Format: "text/plain", Data: summary}}
 	}
 	output.Defs = append(output.Defs, def)
 
-	return nil
+	// Primaries and operators win over options of the same name, which
+	// GNU pages document alongside them.
+	exprs := p.expressions()
+	seen := map[string]bool{}
+	for _, t := range exprs {
+		seen[t.Name] = true
+	}
+	operands := p.operands()
+	if len(operands) == 0 && wrapperUtilities[name] {
+		operands = p.synopsisOperands()
+	}
+	p.supplyOperands(opts, operands)
+	for _, o := range opts {
+		if seen[o.Name] {
+			continue
+		}
+		seen[o.Name] = true
+		o.History = optionHistory[o.Name]
+		optDef, err := makeOptionDef(def, "option", o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create option def: %s", err)
+		}
+		output.Defs = append(output.Defs, optDef)
+	}
+	for _, o := range operands {
+		if seen[o.Name] {
+			continue
+		}
+		seen[o.Name] = true
+		operandDef, err := makeOptionDef(def, "operand", o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create operand def: %s", err)
+		}
+		output.Defs = append(output.Defs, operandDef)
+	}
+	for _, t := range exprs {
+		termDef, err := makeOptionDef(def, t.Kind, t.pageOption)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s def: %s", t.Kind, err)
+		}
+		output.Defs = append(output.Defs, termDef)
+	}
+	for _, w := range p.shellWords() {
+		wordDef, err := makeShellWordDef(u.Name, page, w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s def: %s", w.Kind, err)
+		}
+		output.Defs = append(output.Defs, wordDef)
+	}
+	for _, b := range p.awkBuiltins() {
+		awkDef, err := makeAwkDef(u.Name, page, b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create awk %s def: %s", b.Kind, err)
+		}
+		output.Defs = append(output.Defs, awkDef)
+	}
+	for _, t := range p.sedTerms() {
+		sedDef, err := makeSedDef(u.Name, page, t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create sed %s def: %s", t.Kind, err)
+		}
+		output.Defs = append(output.Defs, sedDef)
+	}
+	for _, v := range p.shellVariables() {
+		varDef, err := makeVariableDef(u.Name, page, v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create variable def: %s", err)
+		}
+		output.Defs = append(output.Defs, varDef)
+	}
+
+	examples := p.examples()
+	for _, e := range examples {
+		a, err := makeExampleAnn(u.Name, page, e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create example annotation: %s", err)
+		}
+		output.Anns = append(output.Anns, a)
+	}
+
+	return examples, nil
 }
 
-func makeCommandDef(filename string, command string, offset int) (*graph.Def, error) {
+// makeCommandDef makes the def of the command documented by the page p,
+// with the changes made to it in issues of POSIX.
+func makeCommandDef(unitName string, filename string, p *manPage, history []*Change) (*graph.Def, error) {
+	command, offset, groups := p.Name, p.nameOffset(), p.groups()
 	data, err := json.Marshal(DefData{
-		Name:    command,
-		Kind:    "command",
-		Keyword: "command",
+		Name:         command,
+		Kind:         "command",
+		Keyword:      "command",
+		OptionGroups: groups,
+		Obsolescent:  isObsolescent(groups),
+		History:      history,
+		Dataflow:     p.dataflow(),
+		Signals:      p.signalHandling(),
 	})
 	if err != nil {
 		return nil, err
@@ -123,7 +382,7 @@ func makeCommandDef(filename string, command string, offset int) (*graph.Def, er
 	return &graph.Def{
 		DefKey: graph.DefKey{
 			UnitType: "ManPages",
-			Unit:     "man",
+			Unit:     unitName,
 			Path:     filename + "/" + command,
 		},
 		Exported: true,
@@ -136,10 +395,317 @@ func makeCommandDef(filename string, command string, offset int) (*graph.Def, er
 	}, nil
 }
 
+// makeOptionDef makes a def of the given kind ("option", "operand",
+// "primary" or "operator") for a member of the command defined by cmd.
+func makeOptionDef(cmd *graph.Def, kind string, o *pageOption) (*graph.Def, error) {
+	// The type is that of the option's argument, or of the operand
+	// itself; options without an argument have none.
+	var typ, dialect, switches string
+	switch {
+	case kind == "operand":
+		typ = argType(o.Name, o.Doc)
+		dialect = patternDialect(cmd.Name, o.Name, typ, o.Doc)
+	case o.Arg != "":
+		typ = argType(o.Arg, o.Doc)
+		dialect = patternDialect(cmd.Name, o.Arg, typ, o.Doc)
+	case kind == "option":
+		switches = dialectSwitch(o)
+	}
+	nested := wrapperUtilities[cmd.Name] && typ == "command" && kind != "option"
+	var terminators []string
+	if nested {
+		terminators = o.Terminators
+	}
+	data, err := json.Marshal(DefData{
+		Name:        o.Name,
+		Kind:        kind,
+		Keyword:     kind,
+		Type:        typ,
+		Arg:         o.Arg,
+		ArgOptional: o.ArgOptional,
+		Aliases:     o.Aliases,
+
+		OptionGroups: o.Groups,
+		Obsolescent:  isObsolescent(o.Groups),
+		History:      o.History,
+
+		Nested:      nested,
+		Terminators: terminators,
+
+		Dialect:     dialect,
+		SetsDialect: switches,
+
+		SuppliesOperand: o.SuppliesOperand,
+	})
+	if err != nil {
+		return nil, err
+	}
+	def := &graph.Def{
+		DefKey: graph.DefKey{
+			UnitType: cmd.UnitType,
+			Unit:     cmd.Unit,
+			Path:     cmd.Path + "/" + o.Name,
+		},
+		Exported: true,
+		Data:     data,
+		Name:     o.Name,
+		Kind:     kind,
+		File:     cmd.File,
+		DefStart: uint32(o.Start),
+		DefEnd:   uint32(o.End),
+	}
+	if o.Doc != "" {
+		def.Docs = []*graph.DefDoc{{Format: "text/plain", Data: o.Doc}}
+	}
+	return def, nil
+}
+
+// makeShellWordDef makes the def of a reserved word or built-in utility
+// documented in the shell command language page.
+func makeShellWordDef(unitName string, filename string, w *shellWord) (*graph.Def, error) {
+	data, err := json.Marshal(DefData{
+		Name:    w.Name,
+		Kind:    w.Kind,
+		Keyword: w.Keyword,
+	})
+	if err != nil {
+		return nil, err
+	}
+	def := &graph.Def{
+		DefKey: graph.DefKey{
+			UnitType: "ManPages",
+			Unit:     unitName,
+			Path:     filename + "/" + w.Kind + "/" + shellWordPath(w),
+		},
+		Exported: true,
+		Data:     data,
+		Name:     w.Name,
+		Kind:     w.Kind,
+		File:     filename,
+		DefStart: uint32(w.Start),
+		DefEnd:   uint32(w.End),
+	}
+	if w.Doc != "" {
+		def.Docs = []*graph.DefDoc{{Format: "text/plain", Data: w.Doc}}
+	}
+	return def, nil
+}
+
+// makeVariableDef makes the def of a special parameter or variable of
+// the shell. Variables are in the "variable" namespace of the unit, as
+// signals are in "signal", so that the pages of built-ins that document
+// them share the def of the shell page.
+func makeVariableDef(unitName string, filename string, v *shellVariable) (*graph.Def, error) {
+	data, err := json.Marshal(DefData{
+		Name:    v.Name,
+		Kind:    "variable",
+		Keyword: v.Keyword,
+	})
+	if err != nil {
+		return nil, err
+	}
+	def := &graph.Def{
+		DefKey: graph.DefKey{
+			UnitType: "ManPages",
+			Unit:     unitName,
+			Path:     "variable/" + shellVariablePath(v),
+		},
+		Exported: true,
+		Data:     data,
+		Name:     v.Name,
+		Kind:     "variable",
+		File:     filename,
+		DefStart: uint32(v.Start),
+		DefEnd:   uint32(v.End),
+	}
+	if v.Doc != "" {
+		def.Docs = []*graph.DefDoc{{Format: "text/plain", Data: v.Doc}}
+	}
+	return def, nil
+}
+
+// makeSignalDef makes the def of a signal. Signals are in the "signal"
+// namespace of the unit, so that refs to them don't depend on which page
+// documents them.
+func makeSignalDef(unitName string, filename string, s *pageSignal) (*graph.Def, error) {
+	data, err := json.Marshal(DefData{
+		Name:          s.Name,
+		Kind:          "signal",
+		Keyword:       "signal",
+		SignalNumber:  s.Number,
+		DefaultAction: s.Action,
+	})
+	if err != nil {
+		return nil, err
+	}
+	def := &graph.Def{
+		DefKey: graph.DefKey{
+			UnitType: "ManPages",
+			Unit:     unitName,
+			Path:     signalDefPath(s.Name),
+		},
+		Exported: true,
+		Data:     data,
+		Name:     s.Name,
+		Kind:     "signal",
+		File:     filename,
+		DefStart: uint32(s.Start),
+		DefEnd:   uint32(s.End),
+	}
+	if s.Doc != "" {
+		def.Docs = []*graph.DefDoc{{Format: "text/plain", Data: s.Doc}}
+	}
+	return def, nil
+}
+
+// makeExampleAnn makes the "example" annotation of an example, spanning
+// its lines, with the snippet and its prose as Data.
+func makeExampleAnn(unitName string, filename string, e *pageExample) (*ann.Ann, error) {
+	data, err := json.Marshal(e.Example)
+	if err != nil {
+		return nil, err
+	}
+	return &ann.Ann{
+		UnitType:  "ManPages",
+		Unit:      unitName,
+		File:      filename,
+		StartLine: uint32(e.StartLine),
+		EndLine:   uint32(e.EndLine),
+		Type:      "example",
+		Data:      data,
+	}, nil
+}
+
+// makeAwkDef makes the def of a built-in function or variable of awk.
+// They are in the "awk" namespace of the unit rather than under the
+// page, so that refs from awk programs don't depend on which page of
+// the unit documents awk.
+func makeAwkDef(unitName string, filename string, b *awkBuiltin) (*graph.Def, error) {
+	data, err := json.Marshal(DefData{
+		Name:    b.Name,
+		Kind:    b.Kind,
+		Keyword: "awk " + b.Kind,
+		Type:    b.Signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	def := &graph.Def{
+		DefKey: graph.DefKey{
+			UnitType: "ManPages",
+			Unit:     unitName,
+			Path:     "awk/" + b.Name,
+		},
+		Exported: true,
+		Data:     data,
+		Name:     b.Name,
+		Kind:     b.Kind,
+		File:     filename,
+		DefStart: uint32(b.Start),
+		DefEnd:   uint32(b.End),
+	}
+	if b.Doc != "" {
+		def.Docs = []*graph.DefDoc{{Format: "text/plain", Data: b.Doc}}
+	}
+	return def, nil
+}
+
+// makeSedDef makes the def of an editing command of sed or a flag of its
+// s command, in the "sed" namespace of the unit.
+func makeSedDef(unitName string, filename string, t *sedTerm) (*graph.Def, error) {
+	keyword := "sed command"
+	if t.Kind == "flag" {
+		keyword = "sed flag"
+	}
+	data, err := json.Marshal(DefData{
+		Name:    t.Name,
+		Kind:    t.Kind,
+		Keyword: keyword,
+		Type:    t.Syntax,
+	})
+	if err != nil {
+		return nil, err
+	}
+	def := &graph.Def{
+		DefKey: graph.DefKey{
+			UnitType: "ManPages",
+			Unit:     unitName,
+			Path:     sedTermPath(t),
+		},
+		Exported: true,
+		Data:     data,
+		Name:     t.Name,
+		Kind:     t.Kind,
+		File:     filename,
+		DefStart: uint32(t.Start),
+		DefEnd:   uint32(t.End),
+	}
+	if t.Doc != "" {
+		def.Docs = []*graph.DefDoc{{Format: "text/plain", Data: t.Doc}}
+	}
+	return def, nil
+}
+
 type DefData struct {
-	Name      string
-	Keyword   string
+	Name    string
+	Keyword string
+
+	// Type is the type of an option's argument or of an operand, as
+	// classified by argType, e.g. "file" or "number".
 	Type      string
 	Kind      string
 	Separator string
+
+	// Arg is the placeholder for an option's argument, e.g. "number",
+	// and ArgOptional is set if the argument may be omitted, as in
+	// "--color[=WHEN]".
+	Arg         string `json:",omitempty"`
+	ArgOptional bool   `json:",omitempty"`
+
+	// Aliases are the other names of an option, linking a GNU-style
+	// long option to its short equivalent and vice versa.
+	Aliases []string `json:",omitempty"`
+
+	// OptionGroups are the POSIX option groups a command or option
+	// belongs to, from the margin codes of its page, e.g. "XSI".
+	// Obsolescent is set for those marked OB, which may be removed from
+	// a future version of the standard.
+	OptionGroups []string `json:",omitempty"`
+	Obsolescent  bool     `json:",omitempty"`
+
+	// History lists the issues of POSIX in which a command or option was
+	// introduced, changed or removed.
+	History []*Change `json:",omitempty"`
+
+	// Nested is set for the operands and primaries of wrapper utilities
+	// that introduce a nested command, such as xargs's utility operand
+	// or find's -exec. The nested command runs to the end of the command
+	// line or to one of Terminators, e.g. ";" or "+" for -exec.
+	Nested      bool     `json:",omitempty"`
+	Terminators []string `json:",omitempty"`
+
+	// Dialect is the language of the patterns an option-argument or
+	// operand takes: "BRE", "ERE" or "glob". SetsDialect is set for the
+	// options that change the dialect of the command's patterns, such as
+	// grep's -E ("ERE") or -F ("fixed").
+	Dialect     string `json:",omitempty"`
+	SetsDialect string `json:",omitempty"`
+
+	// SuppliesOperand is the operand an option stands in for, such as
+	// sed's script for -e and -f. Commands given the option take their
+	// operands without it.
+	SuppliesOperand string `json:",omitempty"`
+
+	// Dataflow summarizes how a command uses its standard input and
+	// output and its files.
+	Dataflow *Dataflow `json:",omitempty"`
+
+	// Signals summarizes how a command responds to signals.
+	Signals *SignalHandling `json:",omitempty"`
+
+	// SignalNumber is the number POSIX mandates for a signal, if any,
+	// and DefaultAction what the signal does to a process that doesn't
+	// catch it: "terminate", "abort", "ignore", "stop" or "continue".
+	SignalNumber  int    `json:",omitempty"`
+	DefaultAction string `json:",omitempty"`
 }
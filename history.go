@@ -0,0 +1,153 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A Change records a change made to a command or option in an issue of
+// POSIX, from the CHANGE HISTORY section of its page.
+type Change struct {
+	Issue int
+
+	// Kind is one of "added", "changed", "obsolescent" or "removed".
+	Kind string
+
+	// Note is the sentence the change was taken from, e.g. "The −h
+	// option is added."
+	Note string `json:",omitempty"`
+
+	// Option is set for changes to the command that concern one of its
+	// options the page no longer documents, as with "The obsolescent −r
+	// option is removed."
+	Option string `json:",omitempty"`
+}
+
+var (
+	// firstReleased matches the sentence giving the issue a command was
+	// introduced in: "First released in Issue 2."
+	firstReleased = regexp.MustCompile(`First released in Issue (\d+)`)
+
+	// issueHeading matches the subsection headings of CHANGE HISTORY,
+	// such as "Issue 7" or "Issue 4, Version 2".
+	issueHeading = regexp.MustCompile(`^Issue (\d+)\b`)
+
+	// sentenceEnd matches the end of a sentence in a paragraph.
+	sentenceEnd = regexp.MustCompile(`\.\s+`)
+
+	// optionMention matches an option named in prose, such as "−h" in
+	// "The −h option is added." or "−number" in "The obsolescent −number
+	// form is removed."
+	optionMention = regexp.MustCompile(`(?:^|[\s(])([−‐-][A-Za-z0-9]+)\b`)
+
+	// formMention matches the word "form" in a sentence about a form of
+	// the command, such as its historical syntax, rather than the command
+	// itself.
+	formMention = regexp.MustCompile(`\bforms?\b`)
+)
+
+// history parses the CHANGE HISTORY section of p. It returns the
+// changes to the command, and the changes to each of the named options
+// keyed by option name. Sentences mentioning an option the page doesn't
+// document, such as one that was removed, are kept with the changes to
+// the command, with Option set. Those and the sentences about a form of
+// the command never describe the command itself, so they count as
+// changes to it even if they tell of a removal.
+func (p *manPage) history(options map[string]bool) (command []*Change, byOption map[string][]*Change) {
+	byOption = map[string][]*Change{}
+	issue := 0
+	var para []string
+	flush := func() {
+		text := joinLines(para)
+		para = nil
+		if text == "" {
+			return
+		}
+		for _, sentence := range splitSentences(text) {
+			if m := firstReleased.FindStringSubmatch(sentence); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				command = append(command, &Change{Issue: n, Kind: "added", Note: sentence})
+				continue
+			}
+			if issue == 0 {
+				continue
+			}
+			kind := changeKind(sentence)
+			mentioned := map[string]bool{}
+			for _, m := range optionMention.FindAllStringSubmatch(sentence, -1) {
+				name := normalizeDashes(m[1])
+				if mentioned[name] {
+					continue
+				}
+				mentioned[name] = true
+				if options[name] {
+					byOption[name] = append(byOption[name], &Change{Issue: issue, Kind: kind, Note: sentence})
+				} else {
+					command = append(command, &Change{Issue: issue, Kind: partKind(kind), Note: sentence, Option: name})
+				}
+			}
+			if len(mentioned) == 0 {
+				if formMention.MatchString(sentence) {
+					kind = partKind(kind)
+				}
+				command = append(command, &Change{Issue: issue, Kind: kind, Note: sentence})
+			}
+		}
+	}
+
+	for _, l := range p.lines("CHANGE HISTORY") {
+		text := strings.TrimSpace(l.Text)
+		if m := issueHeading.FindStringSubmatch(text); m != nil && l.Indent <= 3 {
+			flush()
+			issue, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if l.blank() {
+			flush()
+			continue
+		}
+		para = append(para, l.Text)
+	}
+	flush()
+	return command, byOption
+}
+
+// splitSentences splits a paragraph into sentences.
+func splitSentences(text string) []string {
+	var sentences []string
+	for _, s := range sentenceEnd.Split(text, -1) {
+		if s = strings.TrimSpace(s); s != "" {
+			if !strings.HasSuffix(s, ".") {
+				s += "."
+			}
+			sentences = append(sentences, s)
+		}
+	}
+	return sentences
+}
+
+// partKind returns the kind of a change to the command made by a change
+// of the given kind to a part of it, such as an option or a form: the
+// command itself is neither removed nor made obsolescent.
+func partKind(kind string) string {
+	if kind == "removed" || kind == "obsolescent" {
+		return "changed"
+	}
+	return kind
+}
+
+// changeKind classifies the change described by a sentence of a CHANGE
+// HISTORY section.
+func changeKind(sentence string) string {
+	s := strings.ToLower(sentence)
+	switch {
+	case strings.Contains(s, "obsolescent") && !strings.Contains(s, "removed"):
+		return "obsolescent"
+	case strings.Contains(s, " removed") || strings.Contains(s, " withdrawn") || strings.Contains(s, " deleted"):
+		return "removed"
+	case strings.Contains(s, " added") || strings.Contains(s, " introduced") || strings.Contains(s, " new "):
+		return "added"
+	}
+	return "changed"
+}
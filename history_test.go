@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	text := `CHANGE HISTORY
+       First released in Issue 2.
+
+   Issue 6
+       The obsolescent −r option is removed. The −f option is marked
+       obsolescent. The −f and −f forms are clarified.
+
+   Issue 7
+       The −n option is added.  SD5‐XCU‐ERN‐97 is applied, updating the
+       SYNOPSIS.
+`
+	p := parsePage("tail.1p.txt", "tail", "1p", text)
+	command, byOption := p.history(map[string]bool{"-f": true, "-n": true})
+
+	format := func(changes []*Change) string {
+		var s []string
+		for _, c := range changes {
+			s = append(s, fmt.Sprintf("%d %s %s", c.Issue, c.Kind, c.Option))
+		}
+		return strings.Join(s, ", ")
+	}
+	tests := []struct {
+		name    string
+		changes []*Change
+		want    string
+	}{
+		{"tail", command, "2 added , 6 changed -r, 7 changed "},
+		{"-f", byOption["-f"], "6 obsolescent , 6 changed "},
+		{"-n", byOption["-n"], "7 added "},
+		{"-r", byOption["-r"], ""},
+	}
+	for _, test := range tests {
+		if got := format(test.changes); got != test.want {
+			t.Errorf("%s: got changes %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHistoryForms(t *testing.T) {
+	tests := []struct {
+		text    string
+		options map[string]bool
+		want    string
+	}{
+		// head's historical "head -5" form is gone, not head.
+		{"The obsolescent −number form is removed.", map[string]bool{"-n": true}, "6 changed -number"},
+		{"The obsolescent form is removed.", nil, "6 changed "},
+		{"The utility is removed.", nil, "6 removed "},
+		{"The utility is marked obsolescent.", nil, "6 obsolescent "},
+	}
+	for _, test := range tests {
+		p := parsePage("head.1p.txt", "head", "1p", "CHANGE HISTORY\n   Issue 6\n       "+test.text+"\n")
+		command, _ := p.history(test.options)
+		var got []string
+		for _, c := range command {
+			got = append(got, fmt.Sprintf("%d %s %s", c.Issue, c.Kind, c.Option))
+		}
+		if strings.Join(got, ", ") != test.want {
+			t.Errorf("%q: got changes %q, want %q", test.text, strings.Join(got, ", "), test.want)
+		}
+	}
+}
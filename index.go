@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// graphSource is embedded in the commands that work off man page defs,
+// which come either from a previously produced graph output or from
+// graphing the man pages under the current directory.
+type graphSource struct {
+	Graph string `long:"graph" description:"read defs from this graph output JSON file instead of graphing the man pages under the current directory"`
+}
+
+// load returns an index of the defs from the source.
+func (s *graphSource) load() (*defIndex, error) {
+	if s.Graph == "" {
+		units, err := scan(getCWD())
+		if err != nil {
+			return nil, fmt.Errorf("scanning the path failed with: %s", err)
+		}
+		out, err := graphUnits(units)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to graph source units: %s", err)
+		}
+		return newDefIndex(out), nil
+	}
+
+	out, err := readGraphOutput(s.Graph)
+	if err != nil {
+		return nil, err
+	}
+	return newDefIndex(out), nil
+}
+
+// readGraphOutput reads a graph output JSON file.
+func readGraphOutput(file string) (*graph.Output, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file %s: %s", file, err)
+	}
+	defer f.Close()
+	var out graph.Output
+	if err := json.NewDecoder(f).Decode(&out); err != nil {
+		return nil, fmt.Errorf("Failed to parse graph output %s: %s", file, err)
+	}
+	return &out, nil
+}
+
+// A defIndex indexes the defs of a graph output by command, for looking
+// up commands and their options and operands by name.
+type defIndex struct {
+	output   *graph.Output
+	commands map[string]*graph.Def
+	members  map[string][]*graph.Def // keyed by the command def's path
+	signals  map[string]*graph.Def   // keyed by name, e.g. "SIGINT"
+}
+
+func newDefIndex(out *graph.Output) *defIndex {
+	x := &defIndex{
+		output:   out,
+		commands: map[string]*graph.Def{},
+		members:  map[string][]*graph.Def{},
+		signals:  map[string]*graph.Def{},
+	}
+	for _, def := range out.Defs {
+		switch def.Kind {
+		case "command":
+			// The first def wins, so untranslated pages and earlier
+			// man hierarchies take precedence. A page of its own wins
+			// over the shell command language page's account of a
+			// built-in.
+			if prev, ok := x.commands[def.Name]; !ok || prev.Kind == "builtin" {
+				x.commands[def.Name] = def
+			}
+			continue
+		case "builtin":
+			if _, ok := x.commands[def.Name]; !ok {
+				x.commands[def.Name] = def
+			}
+			continue
+		case "signal":
+			if _, ok := x.signals[def.Name]; !ok {
+				x.signals[def.Name] = def
+			}
+			continue
+		case "keyword", "variable", "function", "editing-command", "flag":
+			continue
+		}
+		if i := strings.LastIndex(def.Path, "/"); i >= 0 {
+			x.members[def.Path[:i]] = append(x.members[def.Path[:i]], def)
+		}
+	}
+	return x
+}
+
+// commandAliases are other names of commands that their pages don't
+// have a def for.
+var commandAliases = map[string]string{
+	"[": "test",
+}
+
+// command returns the def of the named command or built-in utility, or
+// nil.
+func (x *defIndex) command(name string) *graph.Def {
+	if def, ok := x.commands[name]; ok {
+		return def
+	}
+	if alias, ok := commandAliases[name]; ok {
+		return x.commands[alias]
+	}
+	return nil
+}
+
+// member returns the def of the named option or operand of cmd, or nil.
+// As with getopt_long, a long option may be abbreviated to any prefix
+// that is unique among the command's long options.
+func (x *defIndex) member(cmd *graph.Def, name string) *graph.Def {
+	var match *graph.Def
+	matches := 0
+	for _, def := range x.members[cmd.Path] {
+		if def.Name == name {
+			return def
+		}
+		if strings.HasPrefix(name, "--") && def.Kind == "option" && strings.HasPrefix(def.Name, name) {
+			match = def
+			matches++
+		}
+	}
+	if matches == 1 {
+		return match
+	}
+	return nil
+}
+
+// signal returns the def of the signal word names as kill and trap take
+// it, such as "TERM", "SIGTERM" or "15", or nil.
+func (x *defIndex) signal(word string) *graph.Def {
+	return x.signals[signalNameOf(word)]
+}
+
+// membersOf returns the defs of the options and operands of cmd.
+func (x *defIndex) membersOf(cmd *graph.Def) []*graph.Def {
+	return x.members[cmd.Path]
+}
+
+// defDoc returns the plain text documentation of def, or "".
+func defDoc(def *graph.Def) string {
+	for _, doc := range def.Docs {
+		if doc.Format == "text/plain" {
+			return doc.Data
+		}
+	}
+	return ""
+}
+
+// defData returns the decoded Data of def.
+func defData(def *graph.Def) DefData {
+	var data DefData
+	json.Unmarshal(def.Data, &data)
+	return data
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func init() {
+	_, err := flagParser.AddCommand("lint",
+		"check shell scripts for unknown options",
+		"Report invocations in shell scripts that use options the command's man page does not define, or that POSIX marks obsolescent. With no arguments, the shell scripts under the current directory are checked.",
+		&lintCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type LintCmd struct {
+	graphSource
+
+	JSON bool `long:"json" description:"print diagnostics as JSON, for CI annotations"`
+}
+
+var lintCmd LintCmd
+
+func (c *LintCmd) Usage() string {
+	return "[lint-OPTIONS] [SCRIPT...]"
+}
+
+func (c *LintCmd) Execute(args []string) error {
+	index, err := c.load()
+	if err != nil {
+		return err
+	}
+	return checkScripts(index, args, c.JSON, lintScript)
+}
+
+// checkScripts runs check on the scripts named in args, or on the shell
+// scripts under the current directory, and prints the diagnostics it
+// reports. It fails if there are any, so that CI jobs do.
+func checkScripts(index *defIndex, args []string, asJSON bool, check func(index *defIndex, file, src string) []*diagnostic) error {
+	files, err := scriptArgs(args)
+	if err != nil {
+		return err
+	}
+
+	diags := []*diagnostic{}
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("Failed to read %s: %s", file, err)
+		}
+		diags = append(diags, check(index, file, string(src))...)
+	}
+
+	if err := printDiagnostics(diags, asJSON); err != nil {
+		return err
+	}
+	switch len(diags) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("1 problem found")
+	default:
+		return fmt.Errorf("%d problems found", len(diags))
+	}
+}
+
+// scriptArgs returns the scripts named in args or, if there are none,
+// the shell scripts under the current directory.
+func scriptArgs(args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	units, err := scanScripts(getCWD())
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, u := range units {
+		files = append(files, u.Files...)
+	}
+	return files, nil
+}
+
+// A diagnostic is a problem found in a shell script.
+type diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Command string
+	Word    string `json:",omitempty"`
+	Message string
+}
+
+func (d *diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// printDiagnostics writes diags to STDOUT, one per line or as a JSON
+// array.
+func printDiagnostics(diags []*diagnostic, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(os.Stdout).Encode(diags)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	for _, d := range diags {
+		fmt.Fprintln(w, d)
+	}
+	return w.Flush()
+}
+
+// lintScript reports the options used in the shell script src that the
+// man pages of their commands don't define, and the commands and
+// options that POSIX marks obsolescent. Commands without a man page, or
+// whose page documents no options at all, are not checked for unknown
+// options.
+func lintScript(index *defIndex, file, src string) []*diagnostic {
+	var diags []*diagnostic
+	report := func(cmd, b *binding, msg string) {
+		line, col := position(src, b.Start)
+		diags = append(diags, &diagnostic{
+			File:    file,
+			Line:    line,
+			Column:  col,
+			Command: cmd.Text,
+			Word:    b.Text,
+			Message: fmt.Sprintf("%s: %s", cmd.Text, msg),
+		})
+	}
+	for _, p := range parseShell(src) {
+		for _, cmd := range p.Commands {
+			// Nested commands, as in "xargs rm -f", are checked against
+			// their own pages.
+			for _, b := range bindCommand(index, cmd.Words) {
+				c := b.Command
+				if c.Def == nil {
+					continue
+				}
+				switch {
+				case b == c && defData(c.Def).Obsolescent:
+					report(c, b, "obsolescent command")
+				case b.Kind == "unknown" && hasOptions(index, c.Def):
+					report(c, b, "unknown option "+b.Text)
+				case b.Kind == "option" && defData(b.Def).Obsolescent:
+					report(c, b, "obsolescent option "+b.Text)
+				}
+			}
+		}
+	}
+	return diags
+}
+
+// hasOptions reports whether the page of the command defined by cmd
+// documents any options.
+func hasOptions(index *defIndex, cmd *graph.Def) bool {
+	for _, def := range index.membersOf(cmd) {
+		if def.Kind == "option" {
+			return true
+		}
+	}
+	return false
+}
+
+// position returns the 1-based line and column (in characters) of the
+// byte offset in src.
+func position(src string, offset int) (line, col int) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	before := src[:offset]
+	line = strings.Count(before, "\n") + 1
+	col = utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+	return line, col
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func init() {
+	_, err := flagParser.AddCommand("lookup",
+		"look up a command and its options",
+		"Print the summary and documentation of a command and, optionally, of some of its options and operands.",
+		&lookupCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type LookupCmd struct {
+	graphSource
+}
+
+var lookupCmd LookupCmd
+
+func (c *LookupCmd) Usage() string {
+	return "[lookup-OPTIONS] COMMAND [OPTION-OR-OPERAND...]"
+}
+
+func (c *LookupCmd) Execute(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("lookup needs a command name")
+	}
+
+	index, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	cmd := index.command(args[0])
+	if cmd == nil {
+		return fmt.Errorf("no man page found for command %q", args[0])
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	fmt.Fprintf(w, "%s - %s\n", cmd.Name, defDoc(cmd))
+	var missing []string
+	for _, name := range args[1:] {
+		def := index.member(cmd, name)
+		if def == nil {
+			missing = append(missing, name)
+			continue
+		}
+		fmt.Fprintln(w)
+		if arg := defData(def).Arg; arg != "" {
+			fmt.Fprintf(w, "%s %s\n", def.Name, arg)
+		} else {
+			fmt.Fprintln(w, def.Name)
+		}
+		fmt.Fprintln(w, indent(defDoc(def), "    "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%s has no option or operand %s", cmd.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// indent prefixes each non-empty line of s with prefix.
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = prefix + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
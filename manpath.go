@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+// manConfigFiles are consulted in order for the system's default man
+// path. man-db uses manpath.config (or man_db.conf on some
+// distributions), while man-1.6 and the BSDs use man.conf.
+var manConfigFiles = []string{
+	"/etc/manpath.config",
+	"/etc/man_db.conf",
+	"/etc/man.conf",
+	"/etc/man.config",
+}
+
+// defaultManpath is used when neither MANPATH nor a configuration file
+// names any man hierarchy.
+var defaultManpath = []string{
+	"/usr/local/share/man",
+	"/usr/local/man",
+	"/usr/share/man",
+	"/usr/man",
+}
+
+// manPageFile matches the names of installed man pages, such as
+// "ls.1", "ls.1p", "ls.1.gz" or "ls.1p.txt", capturing the section. Only
+// the compressions readPage decodes are matched.
+var manPageFile = regexp.MustCompile(`^[^.].*\.([0-9n][a-z0-9]*)(\.(gz|bz2|z|txt))?$`)
+
+// compressedExt matches the extension of a compressed man page.
+var compressedExt = regexp.MustCompile(`\.(gz|bz2|z)$`)
+
+// isManPage reports whether the file at path is named as a man page in
+// the directory of its section, as "man1/ls.1.gz" is, or is a rendered
+// page such as "ls.1p.txt", which may be anywhere. Other files named
+// like pages, such as "lib/libfoo.so.6" or "README.1st", are not pages.
+func isManPage(path string) bool {
+	m := manPageFile.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return false
+	}
+	if strings.HasSuffix(path, ".txt") {
+		return true
+	}
+	d := sectionDir.FindStringSubmatch(filepath.Base(filepath.Dir(path)))
+	return d != nil && strings.HasPrefix(m[1], d[1])
+}
+
+// resolveManpath returns the man hierarchies to scan, in order of
+// precedence. Paths given with --manpath win; otherwise MANPATH is
+// honored, with empty components standing for the configured default
+// as man-db does. Directories that do not exist are dropped.
+func resolveManpath(flagPaths []string, env string, configFile string) ([]string, error) {
+	var paths []string
+	if len(flagPaths) > 0 {
+		for _, p := range flagPaths {
+			paths = append(paths, filepath.SplitList(p)...)
+		}
+		return existingDirs(paths), nil
+	}
+
+	defaults, err := configManpath(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(defaults) == 0 {
+		defaults = defaultManpath
+	}
+
+	if env == "" {
+		return existingDirs(defaults), nil
+	}
+	for _, p := range filepath.SplitList(env) {
+		if p == "" {
+			paths = append(paths, defaults...)
+		} else {
+			paths = append(paths, p)
+		}
+	}
+	return existingDirs(paths), nil
+}
+
+// configManpath reads the man hierarchies listed in a man-db
+// manpath.config or a man.conf style file. If configFile is empty, the
+// first of manConfigFiles that exists is read.
+func configManpath(configFile string) ([]string, error) {
+	if configFile == "" {
+		for _, f := range manConfigFiles {
+			if _, err := os.Stat(f); err == nil {
+				configFile = f
+				break
+			}
+		}
+		if configFile == "" {
+			return nil, nil
+		}
+	}
+
+	f, err := os.Open(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening man configuration %s failed with: %s", configFile, err)
+	}
+	defer f.Close()
+
+	var paths []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "MANDATORY_MANPATH", "MANPATH", "manpath":
+			paths = append(paths, fields[1])
+		case "MANPATH_MAP":
+			if len(fields) > 2 {
+				paths = append(paths, fields[2])
+			}
+		case "MANDB_MAP":
+			paths = append(paths, fields[1])
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading man configuration %s failed with: %s", configFile, err)
+	}
+	return paths, nil
+}
+
+// existingDirs returns the directories in paths that exist, without
+// duplicates and in their original order.
+func existingDirs(paths []string) []string {
+	var dirs []string
+	seen := map[string]bool{}
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			dirs = append(dirs, p)
+		}
+	}
+	return dirs
+}
+
+// scanManpath produces a unit for each man hierarchy in dirs (and for
+// each translation within it), named after the directory the pages
+// were found in. When the same page is installed in several
+// hierarchies, only the one in the earliest hierarchy is kept.
+func scanManpath(dirs []string) ([]*unit.SourceUnit, error) {
+	var units []*unit.SourceUnit
+	seen := map[string]bool{}
+
+	for _, dir := range dirs {
+		localized := map[string][]string{}
+
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return fmt.Errorf("walking directory %s failed with: %s", dir, err)
+			}
+			if !isManPage(path) || !isPageFile(path, info) {
+				return nil
+			}
+			relpath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return fmt.Errorf("making path %s relative to %s failed with: %s", path, dir, err)
+			}
+			locale := pageLocale(relpath)
+			section := filepath.Base(filepath.Dir(relpath))
+			key := locale + "/" + section + "/" + compressedExt.ReplaceAllString(info.Name(), "")
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+			localized[locale] = append(localized[locale], path)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scanning for man pages failed with: %s", err)
+		}
+
+		var locales []string
+		for locale := range localized {
+			locales = append(locales, locale)
+		}
+		sort.Strings(locales) // the untranslated pages ("") sort first
+		for _, locale := range locales {
+			u := &unit.SourceUnit{
+				Key: unit.Key{
+					Name: dir,
+					Type: "ManPages",
+				},
+				Info: unit.Info{
+					Files:  localized[locale],
+					Dir:    dir,
+					Config: map[string]string{"manpath": dir},
+				},
+			}
+			if locale != "" {
+				u.Name = filepath.Join(dir, locale)
+				u.Config["locale"] = locale
+				u.Config["canonical"] = dir
+			}
+			units = append(units, u)
+		}
+	}
+
+	return units, nil
+}
+
+// isPageFile reports whether path is a regular file or a symlink to
+// one. Symlinked pages are kept, since they commonly install a page
+// under an alternative command name.
+func isPageFile(path string, info os.FileInfo) bool {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Stat(path)
+		return err == nil && target.Mode().IsRegular()
+	}
+	return info.Mode().IsRegular()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// marginCodes are the codes POSIX pages put in the margin to mark
+// options and behaviors that belong to an option group, such as XSI
+// (X/Open System Interfaces) or OB (obsolescent).
+var marginCodes = map[string]bool{
+	"ADV": true, "CD": true, "CPT": true, "CX": true, "FD": true,
+	"FR": true, "FSC": true, "IP6": true, "MC1": true, "MF": true,
+	"ML": true, "MLR": true, "MON": true, "MSG": true, "MX": true,
+	"OB": true, "OF": true, "OH": true, "PIO": true, "PS": true,
+	"RPI": true, "RPP": true, "RS": true, "SD": true, "SHM": true,
+	"SIO": true, "SPI": true, "SPN": true, "SS": true, "TCT": true,
+	"TEF": true, "THR": true, "TMO": true, "TMR": true, "TPI": true,
+	"TPP": true, "TPS": true, "TRC": true, "TRI": true, "TRL": true,
+	"TSA": true, "TSH": true, "TSP": true, "TSS": true, "TYM": true,
+	"UP": true, "XSI": true, "XSR": true,
+}
+
+// marginMark matches a bracketed margin code, which may combine
+// several codes as in "[OB XSI]", or one of the "[Option Start]" and
+// "[Option End]" markers delimiting the text it applies to, along with
+// the whitespace before it.
+var marginMark = regexp.MustCompile(`\s*\[(Option Start|Option End|[A-Z][A-Z0-9]{1,3}(?: [A-Z][A-Z0-9]{1,3})*)\]`)
+
+// stripMarginCodes removes the margin codes and option markers from s,
+// returning the codes found in order of appearance, without duplicates,
+// and the remaining text. Bracketed words that aren't known codes, like
+// "[FILE]" in a GNU synopsis, are left alone.
+func stripMarginCodes(s string) (codes []string, rest string) {
+	seen := map[string]bool{}
+	rest = marginMark.ReplaceAllStringFunc(s, func(mark string) string {
+		m := marginMark.FindStringSubmatch(mark)
+		if strings.HasPrefix(m[1], "Option ") {
+			return ""
+		}
+		words := strings.Fields(m[1])
+		for _, w := range words {
+			if !marginCodes[w] {
+				return mark
+			}
+		}
+		for _, w := range words {
+			if !seen[w] {
+				seen[w] = true
+				codes = append(codes, w)
+			}
+		}
+		return ""
+	})
+	if codes == nil && rest == s {
+		return nil, s
+	}
+	return codes, strings.TrimSpace(rest)
+}
+
+// isObsolescent reports whether the option groups include OB, which
+// marks features that may be removed from a future version of POSIX.
+func isObsolescent(groups []string) bool {
+	return contains(groups, "OB")
+}
+
+// contains reports whether list contains s.
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// groups returns the option groups of the utility documented by p, as
+// marked at the start of its SYNOPSIS, e.g. "[XSI] cal [[month] year]".
+func (p *manPage) groups() []string {
+	synopsis := strings.TrimSpace(p.body("SYNOPSIS"))
+	var groups []string
+	for {
+		m := marginMark.FindStringSubmatchIndex(synopsis)
+		if m == nil || m[0] != 0 {
+			return groups
+		}
+		codes, _ := stripMarginCodes(synopsis[:m[1]])
+		if codes == nil && !strings.HasPrefix(synopsis[m[2]:m[3]], "Option ") {
+			return groups
+		}
+		groups = append(groups, codes...)
+		synopsis = strings.TrimSpace(synopsis[m[1]:])
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A pageOption is an option or operand documented in a man page.
+type pageOption struct {
+	Name string // e.g. "-n", "--all" or "file"
+	Arg  string // the option-argument placeholder, e.g. "number"
+	Doc  string
+
+	// ArgOptional is set for optional arguments, as in "--color[=WHEN]"
+	// or "-l [signal]".
+	ArgOptional bool
+
+	// Aliases are the other names of the option listed with it, as in
+	// "-a, --all".
+	Aliases []string
+
+	// Groups are the POSIX option groups the option belongs to.
+	Groups []string
+
+	// History lists the changes made to the option in issues of POSIX.
+	History []*Change
+
+	// Terminators are the words that may end the nested command a
+	// primary introduces, such as ";" and "+" for find's -exec.
+	Terminators []string
+
+	// SuppliesOperand is the operand an option stands in for, as sed's
+	// -e does for script.
+	SuppliesOperand string
+
+	// Start and End are the byte offsets of Name in the page text.
+	Start, End int
+}
+
+// optionAlias matches one of the comma-separated names in the tag of
+// an option, once dashes have been normalized: "-u", "-n number",
+// "--all", "--width=COLS", "--color[=WHEN]" or, with an optional
+// argument attached to a short option, GNU sed's "-i[SUFFIX]". An
+// optional argument may also follow a space, as in kill's "-l [signal]".
+var optionAlias = regexp.MustCompile(`^([-+][^\s,=\[]+)(?:\[=?([^\]]+)\]|=(\S+)|\s+(\S.*))?$`)
+
+// operandTag matches the tag of an entry in the OPERANDS section, such
+// as "file" or "source_file".
+var operandTag = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*(\.\.\.)?$`)
+
+// options returns the options documented in the OPTIONS section of p.
+// Other pages than the POSIX ones also document options in DESCRIPTION,
+// as GNU coreutils do, so that section is searched as well.
+func (p *manPage) options() []*pageOption {
+	entries := p.entries("OPTIONS")
+	if !isPOSIXSection(p.Section) {
+		entries = append(entries, p.entries("DESCRIPTION")...)
+	}
+	var opts []*pageOption
+	for _, e := range entries {
+		opts = append(opts, parseOptionTag(e)...)
+	}
+	return opts
+}
+
+// parseOptionTag returns the options named in the tag of e, or nil if
+// the tag does not name options. Aliases listed together, as in
+// "-w, --width=COLS", share their argument and are linked to each
+// other. Names that can't be parsed are skipped, keeping the others.
+func parseOptionTag(e *pageEntry) []*pageOption {
+	var opts []*pageOption
+	offset := 0
+	for _, part := range strings.Split(e.Tag, ", ") {
+		// A placeholder such as kill's "-<signal>" is no option name.
+		m := optionAlias.FindStringSubmatch(normalizeDashes(part))
+		if m == nil || strings.ContainsAny(m[1], "<>") {
+			offset += len(part) + len(", ")
+			continue
+		}
+		// The part's byte length may differ from the normalized one, so
+		// locate the end of the option name in the original text.
+		nameEnd := strings.IndexAny(part, " \t=[")
+		if nameEnd < 0 {
+			nameEnd = len(part)
+		}
+		o := &pageOption{
+			Name:   m[1],
+			Doc:    e.Doc,
+			Groups: e.Groups,
+			Start:  e.TagStart + offset,
+			End:    e.TagStart + offset + nameEnd,
+		}
+		switch {
+		case m[2] != "":
+			o.Arg, o.ArgOptional = m[2], true
+		case m[3] != "":
+			o.Arg = m[3]
+		case strings.HasPrefix(m[4], "[") && strings.HasSuffix(m[4], "]"):
+			o.Arg, o.ArgOptional = m[4][1:len(m[4])-1], true
+		case m[4] != "":
+			o.Arg = m[4]
+		}
+		o.Arg = strings.TrimSuffix(strings.TrimPrefix(o.Arg, "<"), ">")
+		opts = append(opts, o)
+		offset += len(part) + len(", ")
+	}
+
+	var arg string
+	var optional bool
+	for _, o := range opts {
+		if o.Arg != "" {
+			arg, optional = o.Arg, o.ArgOptional
+			break
+		}
+	}
+	for _, o := range opts {
+		if o.Arg == "" {
+			o.Arg, o.ArgOptional = arg, optional
+		}
+		for _, alias := range opts {
+			if alias != o {
+				o.Aliases = append(o.Aliases, alias.Name)
+			}
+		}
+	}
+	return opts
+}
+
+// operands returns the operands documented in the OPERANDS section of p,
+// in the order the SYNOPSIS gives them, by which they are bound: POSIX
+// sed lists "file" before "script", as in "sed script [file...]".
+func (p *manPage) operands() []*pageOption {
+	var operands []*pageOption
+	for _, e := range p.entries("OPERANDS") {
+		if !operandTag.MatchString(e.Tag) {
+			continue
+		}
+		operands = append(operands, &pageOption{
+			Name:   strings.TrimSuffix(e.Tag, "..."),
+			Doc:    e.Doc,
+			Groups: e.Groups,
+			Start:  e.TagStart,
+			End:    e.TagEnd,
+		})
+	}
+
+	synopsis := p.body("SYNOPSIS")
+	byPosition := optionsByPosition{opts: operands}
+	for _, o := range operands {
+		pos := len(synopsis)
+		if loc := regexp.MustCompile(`\b` + regexp.QuoteMeta(o.Name) + `\b`).FindStringIndex(synopsis); loc != nil {
+			pos = loc[0]
+		}
+		byPosition.pos = append(byPosition.pos, pos)
+	}
+	sort.Stable(byPosition)
+	return operands
+}
+
+// supplyOperands sets SuppliesOperand on the options that stand in for
+// one of operands. The SYNOPSIS gives such commands in several forms, as
+// in "sed [-n] script [file...]" and "sed [-n] -e script [file...]": an
+// operand missing from one form is supplied by the options that form
+// requires.
+func (p *manPage) supplyOperands(opts, operands []*pageOption) {
+	forms := p.synopsisForms(opts)
+	named := map[string]bool{}
+	for _, f := range forms {
+		for _, name := range f.operands {
+			named[name] = true
+		}
+	}
+	byName := map[string]*pageOption{}
+	for _, o := range opts {
+		byName[o.Name] = o
+	}
+	for _, f := range forms {
+		for _, operand := range operands {
+			if !named[operand.Name] || contains(f.operands, operand.Name) {
+				continue
+			}
+			for _, name := range f.required {
+				o := byName[name]
+				if o == nil || o.SuppliesOperand != "" {
+					continue
+				}
+				o.SuppliesOperand = operand.Name
+				for _, alias := range o.Aliases {
+					if a := byName[alias]; a != nil && a.SuppliesOperand == "" {
+						a.SuppliesOperand = operand.Name
+					}
+				}
+			}
+		}
+	}
+}
+
+// A synopsisForm is one of the forms of a command in its SYNOPSIS: the
+// options it requires, outside brackets, and the operands it names.
+type synopsisForm struct {
+	required []string
+	operands []string
+}
+
+// synopsisForms returns the forms of p's SYNOPSIS, each starting with the
+// name of the command. The arguments of the options in opts are skipped,
+// as are optional options with theirs, such as "[-f script_file]".
+func (p *manPage) synopsisForms(opts []*pageOption) []*synopsisForm {
+	takesArg := map[string]bool{}
+	for _, o := range opts {
+		if o.Arg != "" && !o.ArgOptional {
+			takesArg[o.Name] = true
+		}
+	}
+	var forms []*synopsisForm
+	var form *synopsisForm
+	depth, skipDepth, skipping, skipArg := 0, 0, false, false
+	for _, l := range p.lines("SYNOPSIS") {
+		for _, f := range strings.Fields(l.Text) {
+			if f == p.Name {
+				form = &synopsisForm{}
+				forms = append(forms, form)
+				depth, skipping, skipArg = 0, false, false
+				continue
+			}
+			if form == nil {
+				continue
+			}
+
+			name := strings.TrimLeft(f, "[")
+			opens := len(f) - len(name)
+			trimmed := strings.TrimRight(name, "].")
+			closes := strings.Count(name[len(trimmed):], "]")
+			name = normalizeDashes(trimmed)
+
+			d0 := depth
+			depth += opens - closes
+			switch {
+			case skipping:
+			case skipArg:
+				skipArg = false
+			case strings.HasPrefix(name, "-") && d0 == 0 && opens == 0:
+				form.required = append(form.required, name)
+				skipArg = takesArg[name]
+			case strings.HasPrefix(name, "-"):
+				skipping, skipDepth = true, d0
+			case name != "":
+				form.operands = append(form.operands, name)
+			}
+			if skipping && depth <= skipDepth {
+				skipping = false
+			}
+		}
+	}
+	return forms
+}
+
+// optionsByPosition sorts options by their positions in a text.
+type optionsByPosition struct {
+	opts []*pageOption
+	pos  []int
+}
+
+func (v optionsByPosition) Len() int           { return len(v.opts) }
+func (v optionsByPosition) Less(i, j int) bool { return v.pos[i] < v.pos[j] }
+func (v optionsByPosition) Swap(i, j int) {
+	v.opts[i], v.opts[j] = v.opts[j], v.opts[i]
+	v.pos[i], v.pos[j] = v.pos[j], v.pos[i]
+}
+
+// isPOSIXSection reports whether the manual section is one of the POSIX
+// Programmer's Manual, such as "1p".
+func isPOSIXSection(section string) bool {
+	return strings.HasSuffix(section, "p")
+}
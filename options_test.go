@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOptionTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		names    []string
+		arg      string
+		optional bool
+	}{
+		{tag: "-u", names: []string{"-u"}},
+		{tag: "-n number", names: []string{"-n"}, arg: "number"},
+		{tag: "−n number", names: []string{"-n"}, arg: "number"},
+		{tag: "-a, --all", names: []string{"-a", "--all"}},
+		{tag: "-w, --width=COLS", names: []string{"-w", "--width"}, arg: "COLS"},
+		{tag: "--color[=WHEN]", names: []string{"--color"}, arg: "WHEN", optional: true},
+		{tag: "-i[SUFFIX], --in-place[=SUFFIX]", names: []string{"-i", "--in-place"}, arg: "SUFFIX", optional: true},
+		{tag: "-l, --list [signal]", names: []string{"-l", "--list"}, arg: "signal", optional: true},
+		{tag: "-s [signal]", names: []string{"-s"}, arg: "signal", optional: true},
+		{tag: "-s <signal>", names: []string{"-s"}, arg: "signal"},
+		{tag: "-e[eof-str], --eof[=eof-str]", names: []string{"-e", "--eof"}, arg: "eof-str", optional: true},
+		{tag: "-s, --signal=SIGNAL", names: []string{"-s", "--signal"}, arg: "SIGNAL"},
+		{tag: "-E, -r, --regexp-extended", names: []string{"-E", "-r", "--regexp-extended"}},
+
+		// A name that can't be parsed doesn't lose the others.
+		{tag: "-x, some thing, --exact", names: []string{"-x", "--exact"}},
+
+		{tag: "-<signal>, -s <signal>, --signal <signal>", names: []string{"-s", "--signal"}, arg: "signal"},
+		{tag: "-rcvbuf<SIZE>"},
+		{tag: "->"},
+
+		{tag: "file"},
+		{tag: "124"},
+		{tag: "-"},
+	}
+	for _, test := range tests {
+		opts := parseOptionTag(&pageEntry{Tag: test.tag, Doc: "doc"})
+		var names []string
+		for _, o := range opts {
+			names = append(names, o.Name)
+			if o.Arg != test.arg || o.ArgOptional != test.optional {
+				t.Errorf("%q: %s has argument %q (optional %v), want %q (optional %v)", test.tag, o.Name, o.Arg, o.ArgOptional, test.arg, test.optional)
+			}
+			if len(o.Aliases) != len(test.names)-1 {
+				t.Errorf("%q: %s has aliases %v", test.tag, o.Name, o.Aliases)
+			}
+		}
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("%q: got options %v, want %v", test.tag, names, test.names)
+		}
+	}
+}
+
+func TestParseOptionTagOffsets(t *testing.T) {
+	tag := "-x, some thing, --exact"
+	opts := parseOptionTag(&pageEntry{Tag: tag, TagStart: 100})
+	if len(opts) != 2 {
+		t.Fatalf("got %d options, want 2", len(opts))
+	}
+	for _, o := range opts {
+		if got := tag[o.Start-100 : o.End-100]; got != o.Name {
+			t.Errorf("%s spans %q", o.Name, got)
+		}
+	}
+}
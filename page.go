@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A manPage is the text of a man page, split into its top-level
+// sections. Pages written in roff are rendered to plain text first, in
+// which case byte offsets refer to the rendered text.
+type manPage struct {
+	File     string
+	Name     string // the command name, from the file name
+	Section  string // the manual section, from the file name (e.g. "1p")
+	Text     string
+	Sections []*pageSection
+
+	// Roff is set for pages rendered from roff source, whose tagged
+	// paragraphs always have the tag on a line of its own.
+	Roff bool
+
+	// Rendered is set if Text is not the content of File, as for pages
+	// rendered from roff or decompressed, so that offsets in Text locate
+	// nothing in File.
+	Rendered bool
+}
+
+// A pageSection is a top-level section of a man page, such as NAME or
+// OPTIONS. Start and End are the byte offsets of its body in the page
+// text.
+type pageSection struct {
+	Title      string
+	Start, End int
+}
+
+// sectionHeading matches the unindented, upper-case section headings of
+// a rendered man page.
+var sectionHeading = regexp.MustCompile(`(?m)^([A-Z][A-Z0-9 /-]*[A-Z0-9])[ \t]*$`)
+
+// readPage reads and splits the man page at path, which may be
+// compressed and may be either rendered text or roff source.
+func readPage(path string) (*manPage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file %s: %s", path, err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	compressed := true
+	switch filepath.Ext(path) {
+	case ".gz", ".z":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decompress %s: %s", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	case ".bz2":
+		r = bzip2.NewReader(f)
+	default:
+		compressed = false
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", path, err)
+	}
+
+	text := string(data)
+	if isRoff(data) {
+		text = renderRoff(text)
+	}
+	name, section := splitPageName(filepath.Base(path))
+	p := parsePage(path, name, section, text)
+	p.Roff = isRoff(data)
+	p.Rendered = p.Roff || compressed
+	return p, nil
+}
+
+// pageFooter matches the unindented footer line of a rendered man page,
+// such as "IEEE/The Open Group      2013      HEAD(1P)".
+var pageFooter = regexp.MustCompile(`\n[^ \n][^\n]*\S+\([0-9][A-Za-z0-9]*\)[ \t]*\n*$`)
+
+// parsePage splits text into its sections. The page footer is not part
+// of the last section.
+func parsePage(file, name, section, text string) *manPage {
+	p := &manPage{File: file, Name: name, Section: section, Text: text}
+	end := len(text)
+	if m := pageFooter.FindStringIndex(text); m != nil {
+		end = m[0]
+	}
+	matches := sectionHeading.FindAllStringSubmatchIndex(text, -1)
+	for i, m := range matches {
+		if m[0] >= end {
+			break
+		}
+		s := &pageSection{Title: text[m[2]:m[3]], Start: m[1], End: end}
+		if i+1 < len(matches) && matches[i+1][0] < end {
+			s.End = matches[i+1][0]
+		}
+		p.Sections = append(p.Sections, s)
+	}
+	return p
+}
+
+// A pageChapter is a part of a page under a heading that doesn't start a
+// top-level section, such as "2.5.2 Special Parameters" in the shell
+// command language page. Start and End are the byte offsets of its body
+// in the page text.
+type pageChapter struct {
+	Title      string // in lower case
+	Start, End int
+}
+
+// chapters returns the parts of p under the headings matched by the
+// first group of heading. A part ends at the next such heading or
+// top-level section.
+func (p *manPage) chapters(heading *regexp.Regexp) []*pageChapter {
+	matches := heading.FindAllStringSubmatchIndex(p.Text, -1)
+	var chapters []*pageChapter
+	for i, m := range matches {
+		c := &pageChapter{Title: strings.ToLower(p.Text[m[2]:m[3]]), Start: m[1], End: len(p.Text)}
+		if i+1 < len(matches) {
+			c.End = matches[i+1][0]
+		}
+		for _, s := range p.Sections {
+			if h := strings.LastIndex(p.Text[:s.Start], "\n") + 1; h > c.Start && h < c.End {
+				c.End = h
+				break
+			}
+		}
+		chapters = append(chapters, c)
+	}
+	return chapters
+}
+
+// splitPageName splits a man page file name such as "ls.1p.txt" or
+// "ls.1.gz" into the command name and manual section.
+func splitPageName(file string) (name, section string) {
+	file = compressedExt.ReplaceAllString(file, "")
+	file = strings.TrimSuffix(file, ".txt")
+	i := strings.LastIndex(file, ".")
+	if i <= 0 {
+		return file, ""
+	}
+	return file[:i], file[i+1:]
+}
+
+// section returns the first section of p with the given title, or nil.
+func (p *manPage) section(title string) *pageSection {
+	for _, s := range p.Sections {
+		if s.Title == title {
+			return s
+		}
+	}
+	return nil
+}
+
+// body returns the text of the section with the given title, or "".
+func (p *manPage) body(title string) string {
+	if s := p.section(title); s != nil {
+		return p.Text[s.Start:s.End]
+	}
+	return ""
+}
+
+// nameSeparators separate the names from the summary in the NAME
+// section, e.g. "ls — list directory contents".
+var nameSeparators = []string{" — ", " -- ", " - ", " – "}
+
+// summary returns the names and one-line description in the NAME
+// section of p.
+func (p *manPage) summary() (names []string, summary string) {
+	line := strings.Join(strings.Fields(p.body("NAME")), " ")
+	for _, sep := range nameSeparators {
+		if i := strings.Index(line, sep); i >= 0 {
+			for _, n := range strings.Split(line[:i], ",") {
+				if n = strings.TrimSpace(n); n != "" {
+					names = append(names, n)
+				}
+			}
+			_, summary = stripMarginCodes(line[i+len(sep):])
+			return names, strings.TrimSpace(summary)
+		}
+	}
+	if p.Name != "" {
+		names = []string{p.Name}
+	}
+	return names, line
+}
+
+// nameOffset returns the byte offset just past the first occurrence of
+// the command name in the NAME section, or len(p.Name) if there is
+// none, as for pages without a NAME section.
+func (p *manPage) nameOffset() int {
+	if s := p.section("NAME"); s != nil {
+		if i := strings.Index(p.Text[s.Start:s.End], p.Name); i >= 0 {
+			return s.Start + i + len(p.Name)
+		}
+	}
+	return len(p.Name)
+}
+
+// isRoff reports whether data looks like roff source rather than
+// rendered text. Comment lines, such as the copyright notices that may
+// precede the title of a page, are skipped.
+func isRoff(data []byte) bool {
+	n := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte(`.\"`)) || bytes.HasPrefix(line, []byte(`'\"`)) {
+			continue
+		}
+		if bytes.HasPrefix(line, []byte(".TH ")) || bytes.HasPrefix(line, []byte(".SH ")) || bytes.HasPrefix(line, []byte(".Dd ")) {
+			return true
+		}
+		if n++; n == 20 {
+			break
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func init() {
+	_, err := flagParser.AddCommand("patterncheck",
+		"check patterns in shell scripts",
+		"Report literal patterns in shell scripts that are likely mistakes in the dialect their command reads them in, as told by its man page and options: a \"+\" in a BRE, a \"\\(\" in an ERE or a \"^\" in a shell pattern. With no arguments, the shell scripts under the current directory are checked.",
+		&patterncheckCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type PatterncheckCmd struct {
+	graphSource
+
+	JSON bool `long:"json" description:"print diagnostics as JSON, for CI annotations"`
+}
+
+var patterncheckCmd PatterncheckCmd
+
+func (c *PatterncheckCmd) Usage() string {
+	return "[patterncheck-OPTIONS] [SCRIPT...]"
+}
+
+func (c *PatterncheckCmd) Execute(args []string) error {
+	index, err := c.load()
+	if err != nil {
+		return err
+	}
+	return checkScripts(index, args, c.JSON, patterncheckScript)
+}
+
+// expansion matches the parameter expansions and command substitutions
+// that keep a word from being a literal pattern. A "$" not followed by a
+// name, as in "foo$", is an anchor.
+var expansion = regexp.MustCompile("\\$[A-Za-z_{(0-9@*#?!$-]|`")
+
+// patterncheckScript reports the literal patterns in the shell script
+// src that are likely mistakes in the dialect their command reads them
+// in: the dialect of the option-argument or operand, unless an option
+// of the command, like grep's -E, selects another.
+func patterncheckScript(index *defIndex, file, src string) []*diagnostic {
+	var diags []*diagnostic
+	for _, p := range parseShell(src) {
+		for _, cmd := range p.Commands {
+			bindings := bindCommand(index, cmd.Words)
+			switches := map[*binding]string{}
+			for _, b := range bindings {
+				if b.Kind == "option" {
+					if d := defData(b.Def).SetsDialect; d != "" {
+						switches[b.Command] = d
+					}
+				}
+			}
+
+			for _, b := range bindings {
+				if (b.Kind != "operand" && b.Kind != "argument") || b.Def == nil || expansion.MatchString(b.Text) {
+					continue
+				}
+				data := defData(b.Def)
+				dialect := data.Dialect
+				if d, ok := switches[b.Command]; ok && (dialect == "BRE" || dialect == "ERE") {
+					dialect = d
+				}
+				if dialect == "" {
+					continue
+				}
+
+				patterns := []string{b.Text}
+				if b.Command.Text == "sed" && data.Type == "" {
+					patterns = sedRegexes(b.Text)
+				}
+				for _, pattern := range patterns {
+					for _, msg := range patternMistakes(pattern, dialect) {
+						if strings.Contains(msg, "in a BRE") {
+							if o := dialectOption(index, b.Command.Def, "ERE"); o != "" {
+								msg += fmt.Sprintf("; use %s %s for an ERE", b.Command.Text, o)
+							}
+						}
+						line, col := position(src, b.Start)
+						diags = append(diags, &diagnostic{
+							File:    file,
+							Line:    line,
+							Column:  col,
+							Command: b.Command.Text,
+							Word:    pattern,
+							Message: fmt.Sprintf(`%s: pattern "%s": %s`, b.Command.Text, pattern, msg),
+						})
+					}
+				}
+			}
+		}
+	}
+	return diags
+}
+
+// dialectOption returns the shortest option of the command defined by
+// cmd that selects dialect, or "" if it has none.
+func dialectOption(index *defIndex, cmd *graph.Def, dialect string) string {
+	option := ""
+	for _, def := range index.membersOf(cmd) {
+		if def.Kind == "option" && defData(def).SetsDialect == dialect && (option == "" || len(def.Name) < len(option)) {
+			option = def.Name
+		}
+	}
+	return option
+}
+
+// patternMistakes returns the likely mistakes in pattern as one of the
+// given dialect: characters that are special in another dialect but
+// match themselves in this one, and unterminated bracket expressions.
+// Each mistake is reported once. Only BREs, EREs and shell patterns are
+// checked: fixed strings, as grep -F reads, have no special characters.
+func patternMistakes(pattern, dialect string) []string {
+	if dialect != "BRE" && dialect != "ERE" && dialect != "glob" {
+		return nil
+	}
+	var msgs []string
+	seen := map[string]bool{}
+	report := func(msg string) {
+		if !seen[msg] {
+			seen[msg] = true
+			msgs = append(msgs, msg)
+		}
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			e := pattern[i]
+			switch {
+			case dialect == "ERE" && strings.IndexByte("(){}", e) >= 0:
+				report(fmt.Sprintf(`"\%c" matches a literal "%c" in an ERE; groups and intervals are written without backslashes`, e, e))
+			case dialect == "ERE" && e >= '1' && e <= '9':
+				report("back-references are undefined in an ERE")
+			}
+
+		case c == '[':
+			end := bracketEnd(pattern, i, dialect)
+			if end < 0 {
+				report("unterminated bracket expression")
+				return msgs
+			}
+			i = end
+
+		case dialect == "BRE" && i > 0 && (c == '+' || c == '?'):
+			report(fmt.Sprintf(`"%c" matches itself in a BRE, not a repetition`, c))
+		case dialect == "BRE" && c == '|':
+			report(`"|" matches itself in a BRE, not an alternation`)
+		case dialect == "BRE" && c == '{' && i+1 < len(pattern) && pattern[i+1] >= '0' && pattern[i+1] <= '9':
+			report(`"{" matches itself in a BRE; intervals are written \{m,n\}`)
+
+		case dialect == "glob" && c == '|':
+			report(`"|" matches itself in a shell pattern, not an alternation`)
+		case dialect == "glob" && (c == '^' && i == 0 || c == '$' && i == len(pattern)-1):
+			report(fmt.Sprintf(`"%c" is not an anchor in a shell pattern, which always matches the whole name`, c))
+		}
+	}
+	return msgs
+}
+
+// bracketEnd returns the index of the "]" closing the bracket expression
+// that starts at pattern[start], or -1 if it is unterminated. A "]"
+// right after the opening "[" or "[^" is part of the expression, as are
+// character classes such as "[:alpha:]".
+func bracketEnd(pattern string, start int, dialect string) int {
+	i := start + 1
+	if i < len(pattern) && (pattern[i] == '^' || dialect == "glob" && pattern[i] == '!') {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == ']':
+			return i
+		case pattern[i] == '[' && i+1 < len(pattern) && strings.IndexByte(":.=", pattern[i+1]) >= 0:
+			end := strings.Index(pattern[i+2:], string(pattern[i+1])+"]")
+			if end < 0 {
+				return -1
+			}
+			i += 2 + end + 1
+		}
+	}
+	return -1
+}
+
+// sedRegexes returns the regular expressions in a sed script: those of
+// its context addresses, as in "/^#/d", and of its s commands.
+func sedRegexes(script string) []string {
+	var regexes []string
+	// delimited returns the text up to the next unescaped delim at or
+	// after i, and the index just past the delimiter.
+	delimited := func(i int, delim byte) (string, int) {
+		start := i
+		for ; i < len(script) && script[i] != delim && script[i] != '\n'; i++ {
+			if script[i] == '\\' {
+				i++
+			}
+		}
+		if i > len(script) {
+			i = len(script)
+		}
+		return script[start:i], i + 1
+	}
+	for i := 0; i < len(script); {
+		// Addresses: "/re/", "\cREc", line numbers and "$".
+		for i < len(script) {
+			switch c := script[i]; {
+			case c == '/':
+				var re string
+				re, i = delimited(i+1, '/')
+				regexes = append(regexes, re)
+				continue
+			case c == '\\' && i+1 < len(script):
+				var re string
+				re, i = delimited(i+2, script[i+1])
+				regexes = append(regexes, re)
+				continue
+			case c == ' ' || c == '\t' || c == ',' || c == '!' || c == '$' || c == ';' || c == '\n' || c >= '0' && c <= '9':
+				i++
+				continue
+			}
+			break
+		}
+		if i >= len(script) {
+			break
+		}
+		switch script[i] {
+		case '{', '}':
+			i++
+		case 's':
+			if i+1 < len(script) {
+				delim := script[i+1]
+				var re string
+				re, i = delimited(i+2, delim)
+				regexes = append(regexes, re)
+				_, i = delimited(i, delim)
+			}
+			fallthrough
+		default:
+			// The rest of the command: flags, a label, a file name or
+			// the text of a, i and c.
+			for i < len(script) && script[i] != ';' && script[i] != '\n' && script[i] != '}' {
+				i++
+			}
+		}
+	}
+	return regexes
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestPatterncheckScript(t *testing.T) {
+	index := newDefIndex(&graph.Output{Defs: []*graph.Def{
+		testDef(t, "grep.1p/grep", "command", DefData{}),
+		testDef(t, "grep.1p/grep/-E", "option", DefData{SetsDialect: "ERE"}),
+		testDef(t, "grep.1p/grep/-F", "option", DefData{SetsDialect: "fixed"}),
+		testDef(t, "grep.1p/grep/-P", "option", DefData{SetsDialect: "PCRE"}),
+		testDef(t, "grep.1p/grep/-e", "option", DefData{Arg: "pattern_list", Dialect: "BRE", SuppliesOperand: "pattern_list"}),
+		testDef(t, "grep.1p/grep/pattern_list", "operand", DefData{Dialect: "BRE"}),
+		testDef(t, "grep.1p/grep/file", "operand", DefData{Type: "file"}),
+	}})
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"grep 'a+' f", []string{`1:6: grep: pattern "a+": "+" matches itself in a BRE, not a repetition; use grep -E for an ERE`}},
+		{"grep -E 'a+' f", nil},
+		{"grep -E 'a\\(b' f", []string{`1:9: grep: pattern "a\(b": "\(" matches a literal "(" in an ERE; groups and intervals are written without backslashes`}},
+		{"grep '[a' f", []string{`1:6: grep: pattern "[a": unterminated bracket expression`}},
+		{"grep -F '[' f", nil},
+		{"grep -F 'a+' f", nil},
+		{"grep -P '(?<=[)' f", nil},
+		{"grep -e 'x' f+", nil},
+		{"grep \"$re\" f", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, d := range patterncheckScript(index, "t.sh", test.src) {
+			got = append(got, strings.TrimPrefix(d.String(), "t.sh:"))
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.src, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
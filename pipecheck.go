@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	_, err := flagParser.AddCommand("pipecheck",
+		"check pipelines in shell scripts",
+		"Report the stages of pipelines in shell scripts that ignore their standard input, write nothing to their standard output, or are fed binary data where their man page expects text, as told by the STDIN, INPUT FILES, STDOUT and OUTPUT FILES sections of the pages. With no arguments, the shell scripts under the current directory are checked.",
+		&pipecheckCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type PipecheckCmd struct {
+	graphSource
+
+	JSON bool `long:"json" description:"print diagnostics as JSON, for CI annotations"`
+}
+
+var pipecheckCmd PipecheckCmd
+
+func (c *PipecheckCmd) Usage() string {
+	return "[pipecheck-OPTIONS] [SCRIPT...]"
+}
+
+func (c *PipecheckCmd) Execute(args []string) error {
+	index, err := c.load()
+	if err != nil {
+		return err
+	}
+	return checkScripts(index, args, c.JSON, pipecheckScript)
+}
+
+// A pipeStage is a command of a pipeline, with the commands in it that
+// read the pipe and write to it. They differ from the command itself
+// for wrapper utilities: xargs reads its standard input, but leaves its
+// standard output to the command it runs, as nice does both.
+type pipeStage struct {
+	Command        *binding
+	Reader, Writer *binding  // nil if the stage doesn't use the pipe
+	In, Out        *Dataflow // the dataflow of Reader and Writer
+	FileOperands   bool      // whether Reader is given file operands
+}
+
+// newPipeStage returns the stage for the bindings of a command, or nil
+// if none of its commands has a page telling its dataflow.
+func newPipeStage(bindings []*binding) *pipeStage {
+	s := &pipeStage{Command: bindings[0]}
+	known := false
+	for _, b := range bindings {
+		if b.Command != b || b.Def == nil {
+			continue
+		}
+		df := defData(b.Def).Dataflow
+		if df == nil {
+			continue
+		}
+		known = true
+		if df.Stdin && s.Reader == nil {
+			s.Reader, s.In = b, df
+		}
+		if df.Stdout {
+			s.Writer, s.Out = b, df
+		}
+	}
+	if !known {
+		return nil
+	}
+	for _, b := range bindings {
+		if b.Command == s.Reader && b.Kind == "operand" && b.Def != nil && b.Text != "-" && defData(b.Def).Type == "file" {
+			s.FileOperands = true
+		}
+	}
+	return s
+}
+
+// pipecheckScript reports the stages of the pipelines in the shell
+// script src that don't use the pipe feeding them or that they feed, or
+// that are fed binary data where their page expects text. A stage whose
+// standard input or output is redirected, as by "<file" or ">file",
+// doesn't use the pipe whatever its page says. Otherwise, commands
+// without a page telling their dataflow are not checked.
+func pipecheckScript(index *defIndex, file, src string) []*diagnostic {
+	var diags []*diagnostic
+	report := func(name shellToken, msg string) {
+		line, col := position(src, name.Start)
+		diags = append(diags, &diagnostic{
+			File:    file,
+			Line:    line,
+			Column:  col,
+			Command: name.Text,
+			Message: fmt.Sprintf("%s: %s", name.Text, msg),
+		})
+	}
+	for _, p := range parseShell(src) {
+		if len(p.Commands) < 2 {
+			continue
+		}
+		// data is the kind of data flowing into the current stage,
+		// "text", "binary" or "any", or "" if unknown.
+		data := ""
+		for i, cmd := range p.Commands {
+			name := cmd.Words[0]
+			stdin, stdout := redirected(cmd, 0), redirected(cmd, 1)
+			s := newPipeStage(bindCommand(index, cmd.Words))
+			if i > 0 {
+				switch {
+				case stdin:
+					report(name, "reads standard input from a redirection; the pipe's data is ignored")
+				case s == nil:
+				case s.Reader == nil:
+					report(name, "does not read standard input; the pipe's data is ignored")
+				case s.In.StdinDefault && s.FileOperands:
+					report(name, "reads its file operands, not standard input; the pipe's data is ignored")
+				case data == "binary" && s.In.Input == "text":
+					report(name, "expects text input but is fed binary data")
+				}
+			}
+			if i < len(p.Commands)-1 {
+				switch {
+				case stdout:
+					report(name, "redirects standard output; the next stage gets no input")
+				case s != nil && s.Writer == nil:
+					report(name, "writes nothing to standard output; the next stage gets no input")
+				}
+			}
+
+			switch {
+			case s == nil || s.Writer == nil || stdout:
+				data = ""
+			case s.Out.Output == "input":
+				// A copy of the pipe keeps its kind; one of files has
+				// the kind the page allows them.
+				if s.Writer != s.Reader || s.FileOperands {
+					data = s.Out.Input
+				} else if stdin {
+					data = ""
+				}
+			default:
+				data = s.Out.Output
+			}
+		}
+	}
+	return diags
+}
+
+// redirected reports whether cmd redirects the file descriptor fd, 0 for
+// standard input or 1 for standard output, as "<file", "1>file" or
+// "2>&1 >/dev/null" do for them.
+func redirected(cmd *simpleCommand, fd int) bool {
+	for _, r := range cmd.Redirects {
+		op := strings.TrimLeft(r.Op.Text, "0123456789")
+		n := r.Op.Text[:len(r.Op.Text)-len(op)]
+		if n == "" {
+			n = "1"
+			if strings.HasPrefix(op, "<") {
+				n = "0"
+			}
+		}
+		if n == strconv.Itoa(fd) {
+			return true
+		}
+	}
+	return false
+}
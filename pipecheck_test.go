@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestPipecheckScript(t *testing.T) {
+	text := &Dataflow{Stdin: true, StdinDefault: true, Input: "text", Stdout: true, Output: "text"}
+	index := newDefIndex(&graph.Output{Defs: []*graph.Def{
+		testDef(t, "ls.1p/ls", "command", DefData{Dataflow: &Dataflow{Stdout: true, Output: "text"}}),
+		testDef(t, "cat.1p/cat", "command", DefData{Dataflow: &Dataflow{Stdin: true, StdinDefault: true, Input: "any", Stdout: true, Output: "input"}}),
+		testDef(t, "cat.1p/cat/file", "operand", DefData{Type: "file"}),
+		testDef(t, "wc.1p/wc", "command", DefData{Dataflow: text}),
+		testDef(t, "wc.1p/wc/file", "operand", DefData{Type: "file"}),
+		testDef(t, "rm.1p/rm", "command", DefData{Dataflow: &Dataflow{}}),
+	}})
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"ls | cat | wc", nil},
+		{"ls | rm x", []string{"1:6: rm: does not read standard input; the pipe's data is ignored"}},
+		{"ls | cat a | wc", []string{"1:6: cat: reads its file operands, not standard input; the pipe's data is ignored"}},
+		{"ls | cat > x | wc", []string{"1:6: cat: redirects standard output; the next stage gets no input"}},
+		{"ls | cat 2>&1 | wc", nil},
+		{"ls | cat 1>x | wc", []string{"1:6: cat: redirects standard output; the next stage gets no input"}},
+		{"ls | wc < x", []string{"1:6: wc: reads standard input from a redirection; the pipe's data is ignored"}},
+		{"ls | unknown < x | wc", []string{"1:6: unknown: reads standard input from a redirection; the pipe's data is ignored"}},
+		{"ls >/dev/null", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, d := range pipecheckScript(index, "t.sh", test.src) {
+			got = append(got, strings.TrimPrefix(d.String(), "t.sh:"))
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.src, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func init() {
+	_, err := flagParser.AddCommand("portability",
+		"check the portability of options",
+		"Report which options used in shell scripts or a command line are standard, which are specific to some implementations, and which are missing on a target platform, by comparing the option defs of several corpora.",
+		&portabilityCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type PortabilityCmd struct {
+	graphSource
+
+	Corpora  []string `long:"corpus" value-name:"LABEL=FILE" description:"graph output of a corpus to compare, such as gnu=coreutils.json; may be repeated (default: the POSIX pages under the current directory, labeled posix, and each man hierarchy of the others, such as gnu for gnu/man1)"`
+	Standard string   `long:"standard" value-name:"LABEL" default:"posix" description:"corpus whose options are standard"`
+	Target   string   `long:"target" value-name:"LABEL" description:"corpus of the target platform, to report options missing on it"`
+	Command  string   `short:"c" long:"command" description:"check this command line instead of scripts"`
+	JSON     bool     `long:"json" description:"print results as JSON"`
+}
+
+var portabilityCmd PortabilityCmd
+
+func (c *PortabilityCmd) Usage() string {
+	return "[portability-OPTIONS] [SCRIPT...]"
+}
+
+// A corpus is the set of defs built from one collection of man pages,
+// such as the POSIX pages or those of GNU coreutils.
+type corpus struct {
+	Label string
+	Index *defIndex
+}
+
+// A portabilityResult describes where an option used in a script is
+// documented.
+type portabilityResult struct {
+	diagnostic
+	Standard bool     // whether the standard corpus documents the option
+	Corpora  []string // the labels of the corpora documenting the option
+	Missing  bool     // whether the target corpus lacks the option
+}
+
+func (c *PortabilityCmd) Execute(args []string) error {
+	corpora, err := c.loadCorpora()
+	if err != nil {
+		return err
+	}
+	if findCorpus(corpora, c.Standard) == nil {
+		return fmt.Errorf("no corpus labeled %q to take standard options from; give one with --corpus or choose another with --standard", c.Standard)
+	}
+	if c.Target != "" && findCorpus(corpora, c.Target) == nil {
+		return fmt.Errorf("no corpus labeled %q", c.Target)
+	}
+
+	var results []*portabilityResult
+	if c.Command != "" {
+		results = c.check(corpora, "<command>", c.Command)
+	} else {
+		files, err := scriptArgs(args)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("Failed to read %s: %s", file, err)
+			}
+			results = append(results, c.check(corpora, file, string(src))...)
+		}
+	}
+
+	missing := 0
+	for _, r := range results {
+		if r.Missing {
+			missing++
+		}
+	}
+	if c.JSON {
+		if results == nil {
+			results = []*portabilityResult{}
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(results); err != nil {
+			return err
+		}
+	} else {
+		w := bufio.NewWriter(os.Stdout)
+		for _, r := range results {
+			fmt.Fprintln(w, &r.diagnostic)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d of the options used are missing on %s", missing, c.Target)
+	}
+	return nil
+}
+
+// loadCorpora loads the corpora named with --corpus or, if there are
+// none, makes corpora of the default graph source: one of the POSIX
+// pages, labeled "posix" to match the default standard, and one of the
+// other pages of each man hierarchy.
+func (c *PortabilityCmd) loadCorpora() ([]*corpus, error) {
+	var corpora []*corpus
+	if len(c.Corpora) > 0 {
+		for _, spec := range c.Corpora {
+			i := strings.IndexByte(spec, '=')
+			if i <= 0 {
+				return nil, fmt.Errorf("invalid corpus %q, expected LABEL=FILE", spec)
+			}
+			out, err := readGraphOutput(spec[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			corpora = append(corpora, &corpus{Label: spec[:i], Index: newDefIndex(out)})
+		}
+		return corpora, nil
+	}
+
+	index, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	byLabel := map[string]*graph.Output{}
+	var labels []string
+	for _, def := range index.output.Defs {
+		label := corpusLabel(def)
+		out, ok := byLabel[label]
+		if !ok {
+			out = &graph.Output{}
+			byLabel[label] = out
+			labels = append(labels, label)
+		}
+		out.Defs = append(out.Defs, def)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		corpora = append(corpora, &corpus{Label: label, Index: newDefIndex(byLabel[label])})
+	}
+	return corpora, nil
+}
+
+// corpusLabel returns the label of the default corpus def belongs to:
+// "posix" for the POSIX pages, and otherwise the man hierarchy holding
+// the page's section directory, which is how scanManpath names units.
+// The pages under the current directory are all in one unit, so this
+// is what tells gnu/man1/ls.1 apart from bsd/man1/ls.1. Defs of pages
+// outside a hierarchy, such as whatis entries, are labeled by unit.
+func corpusLabel(def *graph.Def) string {
+	if _, section := splitPageName(filepath.Base(def.File)); isPOSIXSection(section) {
+		return "posix"
+	}
+	dir := filepath.Dir(def.File)
+	if !sectionDir.MatchString(filepath.Base(dir)) {
+		return def.Unit
+	}
+	if hierarchy := filepath.Dir(dir); hierarchy != "." {
+		return hierarchy
+	}
+	return def.Unit
+}
+
+// findCorpus returns the corpus with the given label, or nil.
+func findCorpus(corpora []*corpus, label string) *corpus {
+	for _, c := range corpora {
+		if c.Label == label {
+			return c
+		}
+	}
+	return nil
+}
+
+// check reports where each option used in the shell source src is
+// documented. Words are bound using the standard corpus if it has the
+// command, or else the first corpus that does, so that option-arguments
+// are told apart from options.
+func (c *PortabilityCmd) check(corpora []*corpus, file, src string) []*portabilityResult {
+	primary := corpora
+	if std := findCorpus(corpora, c.Standard); std != nil {
+		primary = append([]*corpus{std}, corpora...)
+	}
+
+	var results []*portabilityResult
+	for _, p := range parseShell(src) {
+		for _, cmd := range p.Commands {
+			name := cmd.Words[0].Text
+			var bindings []*binding
+			for _, corp := range primary {
+				if corp.Index.command(name) != nil {
+					bindings = bindCommand(corp.Index, cmd.Words)
+					break
+				}
+			}
+			if bindings == nil {
+				continue
+			}
+
+			for _, b := range bindings {
+				if b.Kind != "option" && (b.Kind != "unknown" || b.Command == b) {
+					continue
+				}
+				// The words of nested commands, as in "xargs rm -f", belong
+				// to their own command.
+				name := b.Command.Text
+				r := &portabilityResult{}
+				for _, corp := range corpora {
+					if def := corp.Index.command(name); def != nil && corp.Index.member(def, b.Text) != nil {
+						r.Corpora = append(r.Corpora, corp.Label)
+						r.Standard = r.Standard || corp.Label == c.Standard
+					}
+				}
+				r.File = file
+				r.Line, r.Column = position(src, b.Start)
+				r.Command = name
+				r.Word = b.Text
+				r.Message = fmt.Sprintf("%s %s: %s", name, b.Text, c.describe(corpora, name, r))
+				results = append(results, r)
+			}
+		}
+	}
+	return results
+}
+
+// describe summarizes where the option in r is documented, setting
+// r.Missing if the target corpus lacks it.
+func (c *PortabilityCmd) describe(corpora []*corpus, command string, r *portabilityResult) string {
+	var desc string
+	switch {
+	case r.Standard:
+		desc = "standard (" + c.Standard + ")"
+	case len(r.Corpora) == 0:
+		desc = "not documented in any corpus"
+	case len(r.Corpora) == 1:
+		desc = r.Corpora[0] + " only"
+	default:
+		desc = strings.Join(r.Corpora, ", ") + " only"
+	}
+
+	if target := findCorpus(corpora, c.Target); target != nil {
+		found := false
+		for _, label := range r.Corpora {
+			found = found || label == c.Target
+		}
+		if !found {
+			r.Missing = true
+			if target.Index.command(command) == nil {
+				desc += "; " + command + " is missing on " + c.Target
+			} else {
+				desc += "; missing on " + c.Target
+			}
+		}
+	}
+	return desc
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestCorpusLabel(t *testing.T) {
+	tests := []struct {
+		unit, file string
+		want       string
+	}{
+		{"man", "posix/man1p/sed.1p.gz", "posix"},
+		{"man", "sed.1p.txt", "posix"},
+		{"man", "gnu/man1/sed.1.gz", "gnu"},
+		{"man", "bsd/man1/sed.1", "bsd"},
+		{"de", "gnu/de/man1/sed.1.gz", "gnu/de"},
+		{"man", "man1/sed.1.gz", "man"},
+		{"man", "sed.1.txt", "man"},
+		{"/usr/share/man", "/usr/share/man/man1/sed.1.gz", "/usr/share/man"},
+		{"whatis", "whatis", "whatis"},
+	}
+	for _, test := range tests {
+		def := &graph.Def{DefKey: graph.DefKey{Unit: test.unit}, File: test.file}
+		if got := corpusLabel(def); got != test.want {
+			t.Errorf("%s in %s: got label %q, want %q", test.file, test.unit, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// renderRoff renders man(7) or mdoc(7) roff source to plain text laid
+// out like man's own output: unindented upper-case section headings,
+// bodies indented by 7 columns and tagged paragraph bodies by 14. It
+// handles only what is needed to find sections, options and their
+// descriptions; there is no filling or hyphenation, so each source line
+// becomes one output line.
+func renderRoff(src string) string {
+	var out []string
+	indent := 7
+	tagNext := false // the next line of text is the tag of a .TP or .HP paragraph
+	tagged := false  // the last line output is such a tag
+	moreTag := false // the next line of text is another tag of the paragraph, after .TQ
+	var pageName string
+
+	text := func(s string) {
+		if moreTag {
+			// The tags of a paragraph are rendered as one, separated
+			// as the aliases of an option are, as in "-s, --signal".
+			out[len(out)-1] += ", " + s
+			moreTag = false
+			return
+		}
+		if tagNext {
+			out = append(out, strings.Repeat(" ", 7)+s)
+			tagNext, tagged = false, true
+			indent = 14
+			return
+		}
+		out = append(out, strings.Repeat(" ", indent)+s)
+		tagged = false
+	}
+
+	for _, line := range strings.Split(src, "\n") {
+		if !strings.HasPrefix(line, ".") && !strings.HasPrefix(line, "'") {
+			text(unescapeRoff(line))
+			continue
+		}
+
+		macro, args := splitRoffRequest(line)
+		switch macro {
+		case "SH", "Sh":
+			out = append(out, "", strings.ToUpper(strings.Join(args, " ")))
+			indent = 7
+		case "SS", "Ss":
+			out = append(out, "", "   "+strings.Join(args, " "))
+			indent = 7
+		case "PP", "P", "LP", "Pp", "sp":
+			out = append(out, "")
+			indent = 7
+		case "TP", "HP":
+			// help2man writes the tag of a .HP paragraph on the next
+			// line and its description after an .IP, as with .TP.
+			out = append(out, "")
+			tagNext = true
+		case "TQ":
+			moreTag = tagged
+			tagNext = !tagged
+			if !tagged {
+				out = append(out, "")
+			}
+		case "IP":
+			// An .IP without a tag right after a .HP tag starts its
+			// description, which follows the tag without a blank line.
+			switch {
+			case len(args) > 0:
+				out = append(out, "", strings.Repeat(" ", 7)+args[0])
+			case !tagged:
+				out = append(out, "")
+			}
+			tagged = false
+			indent = 14
+		case "It":
+			out = append(out, "", strings.Repeat(" ", 7)+renderMdoc(args, pageName))
+			indent = 14
+		case "B", "I", "SM", "SB":
+			text(strings.Join(args, " "))
+		case "BR", "BI", "IR", "RB", "RI", "IB":
+			text(strings.Join(args, ""))
+		case "Nm":
+			// The name given to .Nm is rendered in place of .Nm itself,
+			// and by every later .Nm without one.
+			if len(args) > 0 && !isMdocMacro(args[0]) {
+				if pageName == "" {
+					pageName = args[0]
+				}
+				text(renderMdoc(append([]string{args[0]}, args[1:]...), pageName))
+				continue
+			}
+			text(renderMdoc(append([]string{"Nm"}, args...), pageName))
+		case "Nd":
+			if len(out) > 0 {
+				out[len(out)-1] += " - " + strings.Join(args, " ")
+			}
+		case "Fl", "Ar", "Op", "Cm", "Ic", "Pa", "Xr", "Ev", "Va", "Dl", "Em", "Sy", "Li", "Dq", "Ql", "Sq":
+			text(renderMdoc(append([]string{macro}, args...), pageName))
+		}
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// splitRoffRequest splits a roff request line into its macro name and
+// unescaped arguments, honoring double quotes.
+func splitRoffRequest(line string) (string, []string) {
+	line = strings.TrimLeft(line[1:], " \t")
+	if strings.HasPrefix(line, `\"`) {
+		return "", nil
+	}
+	var fields []string
+	var cur strings.Builder
+	inQuote, started := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '"' && !inQuote:
+			i = len(line) // comment
+		case c == '\\' && i+1 < len(line):
+			// An escape, such as the unpaddable space in ".RI :\  label",
+			// doesn't separate arguments.
+			cur.WriteString(line[i : i+2])
+			i++
+			started = true
+		case c == '"':
+			if inQuote && i+1 < len(line) && line[i+1] == '"' {
+				cur.WriteByte('"')
+				i++
+				continue
+			}
+			inQuote = !inQuote
+			started = true
+		case (c == ' ' || c == '\t') && !inQuote:
+			if started {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				started = false
+			}
+		default:
+			cur.WriteByte(c)
+			started = true
+		}
+	}
+	if started {
+		fields = append(fields, cur.String())
+	}
+	if len(fields) == 0 {
+		return "", nil
+	}
+	args := fields[1:]
+	for i, a := range args {
+		args[i] = unescapeRoff(a)
+	}
+	return fields[0], args
+}
+
+// renderMdoc renders a line of mdoc(7) semantic macros, such as
+// "Fl a Ar file", the way mandoc would.
+func renderMdoc(words []string, pageName string) string {
+	var b strings.Builder
+	space := func() {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+	}
+	flag := false
+	brackets := 0
+	for _, w := range words {
+		switch w {
+		case "Fl":
+			space()
+			b.WriteByte('-')
+			flag = true
+			continue
+		case "Nm":
+			space()
+			b.WriteString(pageName)
+		case "Op":
+			space()
+			b.WriteByte('[')
+			brackets++
+		case "Ar", "Cm", "Ic", "Pa", "Xr", "Ev", "Va", "Dl", "Em", "Sy", "Li", "Dq", "Ql", "Sq", "Ns", "It":
+		default:
+			if !flag {
+				space()
+			}
+			b.WriteString(w)
+		}
+		flag = false
+	}
+	b.WriteString(strings.Repeat("]", brackets))
+	return strings.Replace(b.String(), "[ ", "[", -1)
+}
+
+// isMdocMacro reports whether word is one of the mdoc(7) macros that
+// renderMdoc understands.
+func isMdocMacro(word string) bool {
+	switch word {
+	case "Fl", "Nm", "Op", "Ar", "Cm", "Ic", "Pa", "Xr", "Ev", "Va", "Dl", "Em", "Sy", "Li", "Dq", "Ql", "Sq", "Ns", "It":
+		return true
+	}
+	return false
+}
+
+// roffEscape matches the roff escape sequences that renderRoff
+// understands.
+var roffEscape = regexp.MustCompile(`\\(f\([A-Z]{2}|f\[[^\]]*\]|f.|\(..|\[[^\]]*\]|\*\(..|\*\[[^\]]*\]|\*.|s[-+]?[0-9]|.)`)
+
+// roffChars maps roff special characters to their rendered text.
+var roffChars = map[string]string{
+	`\-`: "-", `\(mi`: "-", `\(hy`: "-", `\(em`: "—", `\(en`: "–",
+	`\[em]`: "—", `\[en]`: "–", `\(aq`: "'", `\(lq`: `"`, `\(rq`: `"`,
+	`\(oq`: "'", `\(cq`: "'", `\(bu`: "*", `\(ti`: "~", `\(ha`: "^",
+	`\[aq]`: "'", `\(dq`: `"`, `\(ga`: "`", `\e`: `\`, `\\`: `\`,
+	`\ `: " ", `\~`: " ", `\.`: ".", `\'`: "'", "\\`": "`",
+}
+
+// unescapeRoff replaces roff escape sequences in s with the text they
+// render to, dropping font changes and other formatting.
+func unescapeRoff(s string) string {
+	if i := strings.Index(s, `\"`); i >= 0 {
+		s = s[:i]
+	}
+	return roffEscape.ReplaceAllStringFunc(s, func(esc string) string {
+		return roffChars[esc]
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderRoff(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			name: "section heading",
+			src:  ".SH description\nText.",
+			want: "\nDESCRIPTION\n       Text.\n",
+		},
+		{
+			name: "TP",
+			src:  ".TP\n\\fB\\-n\\fR\nsuppress output",
+			want: "\n       -n\n              suppress output\n",
+		},
+		{
+			name: "help2man HP",
+			src:  ".HP\n\\fB\\-s\\fR, \\fB\\-\\-signal\\fR=\\fI\\,SIGNAL\\/\\fR\n.IP\nspecify the signal\n.IP\nSIGNAL may be a name",
+			want: "\n       -s, --signal=SIGNAL\n              specify the signal\n\n              SIGNAL may be a name\n",
+		},
+		{
+			name: "TQ",
+			src:  ".TP\n.B \\-<signal>\n.TQ\n.B \\-s <signal>\n.TQ\n.B \\-\\-signal <signal>\nSpecify the signal",
+			want: "\n       -<signal>, -s <signal>, --signal <signal>\n              Specify the signal\n",
+		},
+		{
+			name: "IP with tag",
+			src:  ".IP \\-x\nexact",
+			want: "\n       -x\n              exact\n",
+		},
+		{
+			name: "PP ends indentation",
+			src:  ".TP\n\\-a\nall\n.PP\nmore",
+			want: "\n       -a\n              all\n\n       more\n",
+		},
+		{
+			name: "font macros",
+			src:  ".BR \\-\\-all , \" \\-a\"",
+			want: "       --all, -a\n",
+		},
+		{
+			name: "comment",
+			src:  ".\\\" a comment\ntext",
+			want: "       text\n",
+		},
+		{
+			name: "mdoc",
+			src:  ".Sh SYNOPSIS\n.Nm ls\n.Op Fl a Ar file",
+			want: "\nSYNOPSIS\n       ls\n       [-a file]\n",
+		},
+	}
+	for _, test := range tests {
+		if got := renderRoff(test.src); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, strings.Replace(got, " ", ".", -1), strings.Replace(test.want, " ", ".", -1))
+		}
+	}
+}
+
+func TestHPEntries(t *testing.T) {
+	src := ".SH DESCRIPTION\n.HP\n\\fB\\-k\\fR, \\fB\\-\\-kill\\-after\\fR=\\fI\\,DURATION\\/\\fR\n.IP\nalso send a KILL signal\n.HP\n\\fB\\-s\\fR, \\fB\\-\\-signal\\fR=\\fI\\,SIGNAL\\/\\fR\n.IP\nspecify the signal\n"
+	p := parsePage("timeout.1", "timeout", "1", renderRoff(src))
+	p.Roff = true
+	var names []string
+	for _, o := range p.options() {
+		names = append(names, o.Name+" "+o.Arg)
+	}
+	want := "-k DURATION,--kill-after DURATION,-s SIGNAL,--signal SIGNAL"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("got options %s, want %s", got, want)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"sourcegraph.com/sourcegraph/srclib/unit"
@@ -22,19 +24,40 @@ func init() {
 	}
 }
 
-type ScanCmd struct{}
+type ScanCmd struct {
+	System    bool     `long:"system" description:"scan the system man hierarchy (MANPATH) instead of the current directory"`
+	Manpath   []string `long:"manpath" description:"man hierarchy to scan, in order of precedence; may be repeated or colon-separated (implies --system)"`
+	ManConfig string   `long:"man-config" description:"manpath.config or man.conf file to read the default man path from"`
+}
 
 var scanCmd ScanCmd
 
 func (c *ScanCmd) Execute(args []string) error {
-	scanDir, err := filepath.EvalSymlinks(getCWD())
-	if err != nil {
-		return fmt.Errorf("resolving the path to scan failed with: %s", err)
-	}
+	var units []*unit.SourceUnit
+	if c.System || len(c.Manpath) > 0 {
+		dirs, err := resolveManpath(c.Manpath, os.Getenv("MANPATH"), c.ManConfig)
+		if err != nil {
+			return fmt.Errorf("resolving the man path failed with: %s", err)
+		}
+		units, err = scanManpath(dirs)
+		if err != nil {
+			return fmt.Errorf("scanning the man path failed with: %s", err)
+		}
+	} else {
+		scanDir, err := filepath.EvalSymlinks(getCWD())
+		if err != nil {
+			return fmt.Errorf("resolving the path to scan failed with: %s", err)
+		}
 
-	units, err := scan(scanDir)
-	if err != nil {
-		return fmt.Errorf("scanning the path failed with: %s", err)
+		units, err = scan(scanDir)
+		if err != nil {
+			return fmt.Errorf("scanning the path failed with: %s", err)
+		}
+		scripts, err := scanScripts(scanDir)
+		if err != nil {
+			return fmt.Errorf("scanning the path failed with: %s", err)
+		}
+		units = append(units, scripts...)
 	}
 
 	bytes, err := json.MarshalIndent(units, "", "  ")
@@ -51,18 +74,32 @@ func (c *ScanCmd) Execute(args []string) error {
 
 func scan(scanDir string) ([]*unit.SourceUnit, error) {
 	var units []*unit.SourceUnit
-	var files []string
+	var files, whatis []string
+	localized := map[string][]string{}
 
 	err := filepath.Walk(scanDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return fmt.Errorf("walking directory %s failed with: %s", scanDir, err)
 		}
-		if info.Mode().IsRegular() && strings.HasSuffix(path, ".1p.txt") {
+		if !isPageFile(path, info) {
+			return nil
+		}
+		if isWhatisFile(path) {
+			relpath, err := filepath.Rel(scanDir, path)
+			if err != nil {
+				return fmt.Errorf("making path %s relative to %s failed with: %s", path, scanDir, err)
+			}
+			whatis = append(whatis, relpath)
+		} else if isManPage(path) {
 			relpath, err := filepath.Rel(scanDir, path)
 			if err != nil {
 				return fmt.Errorf("making path %s relative to %s failed with: %s", path, scanDir, err)
 			}
-			files = append(files, relpath)
+			if locale := pageLocale(relpath); locale != "" {
+				localized[locale] = append(localized[locale], relpath)
+			} else {
+				files = append(files, relpath)
+			}
 		}
 		return nil
 	})
@@ -80,5 +117,82 @@ func scan(scanDir string) ([]*unit.SourceUnit, error) {
 		},
 	})
 
+	// whatis databases only provide names and summaries, so they are
+	// kept apart from the pages themselves.
+	if len(whatis) > 0 {
+		units = append(units, &unit.SourceUnit{
+			Key: unit.Key{
+				Name: "whatis",
+				Type: "ManPages",
+			},
+			Info: unit.Info{
+				Files:  whatis,
+				Config: map[string]string{"format": "whatis"},
+			},
+		})
+	}
+
+	// Translated pages get a unit per locale so that they can be
+	// graphed separately and linked back to the canonical pages above.
+	var locales []string
+	for locale := range localized {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	for _, locale := range locales {
+		units = append(units, &unit.SourceUnit{
+			Key: unit.Key{
+				Name: "man/" + locale,
+				Type: "ManPages",
+			},
+			Info: unit.Info{
+				Files:  localized[locale],
+				Config: map[string]string{"locale": locale},
+			},
+		})
+	}
+
 	return units, nil
 }
+
+// localeDir matches the locale directory names used in man hierarchies,
+// such as "de", "pt_BR", "ja_JP.UTF-8" or "sr@latin".
+var localeDir = regexp.MustCompile(`^[a-z]{2,3}(_[A-Z]{2})?(\.[A-Za-z0-9-]+)?(@[a-z]+)?$`)
+
+// notLocaleDirs are directory names that localeDir matches but that hold
+// untranslated pages, as in "doc/man/man1p/ls.1p.txt".
+var notLocaleDirs = map[string]bool{
+	"man": true, "cat": true, "doc": true, "src": true, "lib": true,
+	"usr": true, "opt": true, "etc": true, "var": true, "tmp": true,
+}
+
+// sectionDir matches man section directory names such as "man1" or
+// "man1p", capturing the section.
+var sectionDir = regexp.MustCompile(`^man([0-9n][a-z]*)$`)
+
+// pageLocale returns the locale of the man page at relpath, or "" if
+// the page is untranslated. A directory is only treated as a locale
+// when it directly contains a section directory, as in
+// "de/man1/ls.1.gz".
+func pageLocale(relpath string) string {
+	dirs := strings.Split(filepath.ToSlash(filepath.Dir(relpath)), "/")
+	for i := 0; i+1 < len(dirs); i++ {
+		if localeDir.MatchString(dirs[i]) && !notLocaleDirs[dirs[i]] && sectionDir.MatchString(dirs[i+1]) {
+			return dirs[i]
+		}
+	}
+	return ""
+}
+
+// canonicalPage returns the path of the untranslated page corresponding
+// to the translated page at relpath.
+func canonicalPage(relpath, locale string) string {
+	parts := strings.Split(filepath.ToSlash(relpath), "/")
+	for i := 0; i+2 < len(parts); i++ {
+		if parts[i] == locale && sectionDir.MatchString(parts[i+1]) {
+			parts = append(parts[:i], parts[i+1:]...)
+			break
+		}
+	}
+	return filepath.FromSlash(strings.Join(parts, "/"))
+}
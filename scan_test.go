@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPageLocale(t *testing.T) {
+	tests := []struct {
+		relpath, locale string
+	}{
+		{"man1/ls.1.gz", ""},
+		{"ls.1p.txt", ""},
+		{"de/man1/ls.1.gz", "de"},
+		{"share/man/pt_BR/man1/ls.1", "pt_BR"},
+		{"ja_JP.UTF-8/man1/ls.1", "ja_JP.UTF-8"},
+		{"sr@latin/man8/mount.8", "sr@latin"},
+
+		// Directories that look like locales but hold untranslated pages.
+		{"doc/man/man1p/x.1p.txt", ""},
+		{"man/man1/ls.1", ""},
+		{"usr/man1/ls.1", ""},
+
+		// A locale must directly contain a section directory.
+		{"de/ls.1", ""},
+		{"de/docs/man1/ls.1", ""},
+	}
+	for _, test := range tests {
+		if got := pageLocale(test.relpath); got != test.locale {
+			t.Errorf("pageLocale(%q) = %q, want %q", test.relpath, got, test.locale)
+		}
+	}
+}
+
+func TestCanonicalPage(t *testing.T) {
+	tests := []struct {
+		relpath, locale, canonical string
+	}{
+		{"de/man1/ls.1.gz", "de", "man1/ls.1.gz"},
+		{"/usr/share/man/de/man1/ls.1.gz", "de", "/usr/share/man/man1/ls.1.gz"},
+		{"share/man/pt_BR/man1/ls.1", "pt_BR", "share/man/man1/ls.1"},
+	}
+	for _, test := range tests {
+		if got := canonicalPage(test.relpath, test.locale); got != test.canonical {
+			t.Errorf("canonicalPage(%q, %q) = %q, want %q", test.relpath, test.locale, got, test.canonical)
+		}
+	}
+}
+
+func TestManPageFile(t *testing.T) {
+	tests := []struct {
+		name string
+		page bool
+	}{
+		{"ls.1", true},
+		{"ls.1p", true},
+		{"ls.1.gz", true},
+		{"ls.1p.txt", true},
+		{"signal.h.0p.txt", true},
+		{"signal.7.gz", true},
+		{"ls.1.bz2", true},
+		{"README.md", false},
+		{"main.go", false},
+		{".hidden.1", false},
+
+		// readPage can't decode these.
+		{"ls.1.xz", false},
+		{"ls.1.lzma", false},
+		{"ls.1.Z", false},
+	}
+	for _, test := range tests {
+		if got := manPageFile.MatchString(test.name); got != test.page {
+			t.Errorf("manPageFile matches %q: %v, want %v", test.name, got, test.page)
+		}
+	}
+}
+
+func TestIsManPage(t *testing.T) {
+	tests := []struct {
+		path string
+		page bool
+	}{
+		{"man1/ls.1.gz", true},
+		{"/usr/share/man/de/man1/ls.1.gz", true},
+		{"man3/File::Temp.3pm.gz", true},
+		{"man1/openssl.1ssl.gz", true},
+		{"man1p/ls.1p", true},
+		{"ls.1p.txt", true},
+		{"doc/man/man1p/x.1p.txt", true},
+		{"all/signal.h.0p.txt", true},
+
+		// Files named like pages outside a section directory, or in
+		// that of another section.
+		{"lib/libfoo.so.6", false},
+		{"README.1st", false},
+		{"ls.1", false},
+		{"man3/ls.1", false},
+		{"man1/ls.1.xz", false},
+	}
+	for _, test := range tests {
+		if got := isManPage(test.path); got != test.page {
+			t.Errorf("isManPage(%q) = %v, want %v", test.path, got, test.page)
+		}
+	}
+}
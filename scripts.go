@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	_, err := flagParser.AddCommand("graph-scripts",
+		"graph shell scripts",
+		"Graph shell scripts, producing refs from commands, options and operands to the defs of their man pages.",
+		&graphScriptsCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type GraphScriptsCmd struct {
+	graphSource
+
+	DefRepo string `long:"def-repo" description:"repository that the man page defs belong to, if not the current one"`
+}
+
+var graphScriptsCmd GraphScriptsCmd
+
+func (c *GraphScriptsCmd) Execute(args []string) error {
+	units, err := readUnits()
+	if err != nil {
+		return err
+	}
+
+	// Units may name the graph output to resolve against and the repo
+	// it belongs to in their config; defs are loaded once per source.
+	indexes := map[string]*defIndex{}
+	output := graph.Output{}
+	for _, u := range units {
+		source, defRepo := c.graphSource, c.DefRepo
+		if g := u.Config["graph"]; g != "" {
+			source.Graph = g
+		}
+		if r := u.Config["defRepo"]; r != "" {
+			defRepo = r
+		}
+		index, ok := indexes[source.Graph]
+		if !ok {
+			if index, err = source.load(); err != nil {
+				return err
+			}
+			indexes[source.Graph] = index
+		}
+
+		for _, f := range u.Files {
+			if err := graphScript(index, u, f, defRepo, &output); err != nil {
+				log.Printf("Skipping %s: %s", f, err)
+			}
+		}
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(output); err != nil {
+		return fmt.Errorf("Failed to output graph data: %s", err)
+	}
+	return nil
+}
+
+// graphScript adds refs from the simple commands of the shell script at
+// file to the defs of the commands, options and operands they use.
+func graphScript(index *defIndex, u *unit.SourceUnit, file string, defRepo string, output *graph.Output) error {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("Failed to read %s: %s", file, err)
+	}
+	for _, p := range parseShell(string(src)) {
+		for _, cmd := range p.Commands {
+			for _, b := range bindCommand(index, cmd.Words) {
+				if b.Def == nil || b.Kind == "argument" {
+					continue
+				}
+				output.Refs = append(output.Refs, &graph.Ref{
+					DefRepo:     defRepo,
+					DefUnitType: b.Def.UnitType,
+					DefUnit:     b.Def.Unit,
+					DefPath:     b.Def.Path,
+					UnitType:    u.Type,
+					Unit:        u.Name,
+					File:        file,
+					Start:       uint32(b.Start),
+					End:         uint32(b.End),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// shellScriptExts are the file extensions of shell scripts.
+var shellScriptExts = map[string]bool{".sh": true, ".bash": true, ".ksh": true, ".dash": true}
+
+// shellInterpreters are the interpreters named in the #! line of shell
+// scripts without an extension.
+var shellInterpreters = map[string]bool{"sh": true, "bash": true, "dash": true, "ksh": true, "mksh": true, "ash": true}
+
+// isShellScript reports whether the file at path is a shell script,
+// either by its extension or, for executables without one, by its #!
+// line.
+func isShellScript(path string, info os.FileInfo) bool {
+	ext := filepath.Ext(path)
+	if shellScriptExts[ext] {
+		return true
+	}
+	if ext != "" || info.Mode()&0111 == 0 {
+		return false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	if !strings.HasPrefix(line, "#!") {
+		return false
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) > 1 && filepath.Base(fields[0]) == "env" {
+		fields = fields[1:]
+	}
+	return len(fields) > 0 && shellInterpreters[filepath.Base(fields[0])]
+}
+
+// scanScripts produces a unit of the shell scripts in the directory
+// tree rooted at scanDir, or none if there are no scripts.
+func scanScripts(scanDir string) ([]*unit.SourceUnit, error) {
+	var files []string
+	err := filepath.Walk(scanDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("walking directory %s failed with: %s", scanDir, err)
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != scanDir {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() && isShellScript(path, info) {
+			relpath, err := filepath.Rel(scanDir, path)
+			if err != nil {
+				return fmt.Errorf("making path %s relative to %s failed with: %s", path, scanDir, err)
+			}
+			files = append(files, relpath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scanning for shell scripts failed with: %s", err)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	return []*unit.SourceUnit{{
+		Key: unit.Key{
+			Name: "scripts",
+			Type: "ShellScripts",
+		},
+		Info: unit.Info{
+			Files: files,
+		},
+	}}, nil
+}
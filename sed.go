@@ -0,0 +1,183 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// sedSections are the sections of sed's page that list its editing
+// commands: the POSIX page has them in EXTENDED DESCRIPTION, each
+// prefixed with the number of addresses it takes, and the GNU page in
+// COMMAND SYNOPSIS.
+var sedSections = []string{"EXTENDED DESCRIPTION", "COMMAND SYNOPSIS"}
+
+// sedNames name the editing commands that aren't letters in def paths.
+var sedNames = map[string]string{
+	"{": "lbrace", "}": "rbrace", ":": "colon", "=": "equals",
+	"#": "number-sign", "!": "bang",
+}
+
+var (
+	// sedAddresses matches the prefix giving the number of addresses a
+	// command takes in the POSIX page, as in "[2addr]s/BRE/repl/flags".
+	sedAddresses = regexp.MustCompile(`^\[[012]addr\]\s*`)
+
+	// sedCommand matches the tag of an editing command after the address
+	// prefix: the command character, followed by its arguments, as in
+	// "s/BRE/replacement/flags", "b [label]", ":label" or "a\".
+	sedCommand = regexp.MustCompile(`^([{}=:#!]|[A-Za-z](?:$|[\s\\/]))`)
+
+	// sedFlag matches the tag of a flag of the s command, such as "g" or
+	// "w wfile".
+	sedFlag = regexp.MustCompile(`^([A-Za-z0-9])(?:\s+\S+)?$`)
+)
+
+// A sedTerm is an editing command of sed or a flag of its s command.
+type sedTerm struct {
+	Name string
+	Kind string // "editing-command" or "flag"
+
+	// Syntax is the template of the command or flag, such as
+	// "[2addr]s/BRE/replacement/flags" or "w wfile". Templates spanning
+	// several lines, as for "a\" followed by its text, keep them.
+	Syntax string
+	Doc    string
+
+	// Start and End are the byte offsets of Name in the page text.
+	Start, End int
+}
+
+// sedTerms returns the editing commands and substitution flags that p
+// documents, if it is the page of sed. A command whose template spans
+// several lines, like "a\" and its text, gets the doc that follows the
+// last of them. Commands listed together, as in GNU's "h H", share
+// their doc.
+func (p *manPage) sedTerms() []*sedTerm {
+	if p.Name != "sed" {
+		return nil
+	}
+	var terms []*sedTerm
+	seen := map[string]bool{}
+	for _, title := range sedSections {
+		lines := p.lines(title)
+		base, _ := entryColumns(lines)
+		if base < 0 {
+			continue
+		}
+		entries := map[int]*pageEntry{}
+		for _, e := range p.entriesIn(lines) {
+			entries[e.TagStart] = e
+		}
+		needPrefix := title != "COMMAND SYNOPSIS"
+
+		var pending []*sedTerm
+		for _, l := range lines {
+			if l.blank() || l.Indent != base {
+				continue
+			}
+			tag := strings.TrimRight(l.Text[base:], " ")
+			e := entries[l.Offset+base]
+			if e != nil {
+				tag = e.Tag
+			}
+
+			// Without the address prefix, only a line ending with "\"
+			// continues a command on the next line; others are prose.
+			var cmds []*sedTerm
+			if needPrefix || (pending == nil && (e != nil || strings.HasSuffix(tag, "\\"))) {
+				cmds = parseSedTag(tag, l.Offset+base, needPrefix)
+			}
+			if cmds != nil {
+				pending = cmds
+			} else if pending != nil {
+				for _, c := range pending {
+					c.Syntax += "\n" + tag
+				}
+			} else {
+				continue
+			}
+			if e == nil {
+				continue
+			}
+
+			for _, c := range pending {
+				c.Doc = e.Doc
+				if !seen[c.Name] {
+					seen[c.Name] = true
+					terms = append(terms, c)
+				}
+				if c.Name == "s" {
+					terms = append(terms, sedFlags(p, lines, e)...)
+				}
+			}
+			pending = nil
+		}
+	}
+	return terms
+}
+
+// parseSedTag returns the editing commands named by the tag at offset
+// in the page text, or nil if it names none. If needPrefix is set, the
+// tag must start with the number of addresses the command takes.
+func parseSedTag(tag string, offset int, needPrefix bool) []*sedTerm {
+	rest := tag
+	if m := sedAddresses.FindString(tag); m != "" {
+		rest = tag[len(m):]
+	} else if needPrefix {
+		return nil
+	}
+	start := offset + len(tag) - len(rest)
+
+	// GNU lists commands that differ only in case together: "h H".
+	if fields := strings.Fields(rest); len(fields) > 1 && len(fields[0]) == 1 && strings.EqualFold(fields[0], fields[1]) {
+		return []*sedTerm{
+			{Name: fields[0], Kind: "editing-command", Syntax: fields[0], Start: start, End: start + 1},
+			{Name: fields[1], Kind: "editing-command", Syntax: fields[1], Start: start + strings.LastIndex(rest, fields[1]), End: start + strings.LastIndex(rest, fields[1]) + 1},
+		}
+	}
+	m := sedCommand.FindStringSubmatch(rest)
+	if m == nil {
+		return nil
+	}
+	name := strings.TrimRight(m[1], " \t\\/")
+	return []*sedTerm{{Name: name, Kind: "editing-command", Syntax: tag, Start: start, End: start + len(name)}}
+}
+
+// sedFlags returns the flags of the s command listed in its doc, the
+// entry e among lines.
+func sedFlags(p *manPage, lines []pageLine, e *pageEntry) []*sedTerm {
+	var doc []pageLine
+	for _, l := range lines {
+		if l.Offset > e.TagStart && l.Offset < e.DocEnd {
+			doc = append(doc, l)
+		}
+	}
+	var flags []*sedTerm
+	for _, f := range p.entriesIn(doc) {
+		m := sedFlag.FindStringSubmatch(f.Tag)
+		if m == nil {
+			continue
+		}
+		flags = append(flags, &sedTerm{
+			Name:   m[1],
+			Kind:   "flag",
+			Syntax: f.Tag,
+			Doc:    f.Doc,
+			Start:  f.TagStart,
+			End:    f.TagStart + 1,
+		})
+	}
+	return flags
+}
+
+// sedTermPath returns the path of t's def within the "sed" namespace.
+func sedTermPath(t *sedTerm) string {
+	name := t.Name
+	if alt, ok := sedNames[name]; ok {
+		name = alt
+	}
+	if t.Kind == "flag" {
+		return "sed/s/" + name
+	}
+	return "sed/" + name
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func init() {
+	_, err := flagParser.AddCommand("serve",
+		"serve man page lookups over HTTP",
+		"Serve hover, definition, option listing and completion lookups for editor integrations as JSON over HTTP.",
+		&serveCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type ServeCmd struct {
+	graphSource
+
+	Addr string `long:"addr" description:"address to listen on" default:"localhost:7080"`
+}
+
+var serveCmd ServeCmd
+
+func (c *ServeCmd) Execute(args []string) error {
+	index, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	s := &lookupServer{index: index}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hover", s.handle(s.hover))
+	mux.HandleFunc("/definition", s.handle(s.definition))
+	mux.HandleFunc("/options", s.handle(s.options))
+	mux.HandleFunc("/complete", s.handle(s.complete))
+
+	log.Printf("Serving %d defs on http://%s", len(index.output.Defs), c.Addr)
+	return http.ListenAndServe(c.Addr, mux)
+}
+
+// A lookupServer answers lookups against an index of man page defs.
+//
+// Defs are identified either by name, with the "command" and optional
+// "name" (option or operand) query parameters, or by position, with
+// "line" (a shell command line) and "offset" (a byte offset into it).
+type lookupServer struct {
+	index *defIndex
+}
+
+// errNotFound is returned by handlers when no def matches the query.
+var errNotFound = fmt.Errorf("not found")
+
+// handle adapts a handler returning a JSON-encodable value to an
+// http.HandlerFunc.
+func (s *lookupServer) handle(h func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v, err := h(r)
+		if err == errNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			log.Printf("Failed to write response to %s: %s", r.URL, err)
+		}
+	}
+}
+
+// A hoverResult is the response of /hover.
+type hoverResult struct {
+	Name string
+	Kind string
+	Arg  string `json:",omitempty"`
+	Doc  string
+}
+
+// A defLocation is the response of /definition.
+type defLocation struct {
+	Unit     string
+	Path     string
+	File     string
+	DefStart uint32
+	DefEnd   uint32
+}
+
+func (s *lookupServer) hover(r *http.Request) (interface{}, error) {
+	def, err := s.resolve(r)
+	if err != nil {
+		return nil, err
+	}
+	return &hoverResult{Name: def.Name, Kind: def.Kind, Arg: defData(def).Arg, Doc: defDoc(def)}, nil
+}
+
+func (s *lookupServer) definition(r *http.Request) (interface{}, error) {
+	def, err := s.resolve(r)
+	if err != nil {
+		return nil, err
+	}
+	return &defLocation{Unit: def.Unit, Path: def.Path, File: def.File, DefStart: def.DefStart, DefEnd: def.DefEnd}, nil
+}
+
+func (s *lookupServer) options(r *http.Request) (interface{}, error) {
+	cmd := s.index.command(r.FormValue("command"))
+	if cmd == nil {
+		return nil, errNotFound
+	}
+	results := []*hoverResult{}
+	for _, def := range s.index.membersOf(cmd) {
+		results = append(results, &hoverResult{Name: def.Name, Kind: def.Kind, Arg: defData(def).Arg, Doc: firstParagraph(defDoc(def))})
+	}
+	return results, nil
+}
+
+// complete returns the commands starting with the "command" parameter
+// or, if "prefix" is given, the options of the command starting with
+// it.
+func (s *lookupServer) complete(r *http.Request) (interface{}, error) {
+	results := []*hoverResult{}
+	command, prefix := r.FormValue("command"), r.FormValue("prefix")
+	if _, ok := r.Form["prefix"]; !ok {
+		for name, def := range s.index.commands {
+			if strings.HasPrefix(name, command) {
+				results = append(results, &hoverResult{Name: name, Kind: def.Kind, Doc: defDoc(def)})
+			}
+		}
+	} else {
+		cmd := s.index.command(command)
+		if cmd == nil {
+			return nil, errNotFound
+		}
+		for _, def := range s.index.membersOf(cmd) {
+			if def.Kind != "operand" && strings.HasPrefix(def.Name, prefix) {
+				results = append(results, &hoverResult{Name: def.Name, Kind: def.Kind, Arg: defData(def).Arg, Doc: firstParagraph(defDoc(def))})
+			}
+		}
+	}
+	sort.Sort(hoverResultsByName(results))
+	return results, nil
+}
+
+// resolve returns the def identified by the request's query.
+func (s *lookupServer) resolve(r *http.Request) (*graph.Def, error) {
+	if line := r.FormValue("line"); line != "" {
+		offset, err := strconv.Atoi(r.FormValue("offset"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset: %s", err)
+		}
+		for _, p := range parseShell(line) {
+			for _, cmd := range p.Commands {
+				for _, b := range bindCommand(s.index, cmd.Words) {
+					if b.Def != nil && b.Start <= offset && offset <= b.End {
+						return b.Def, nil
+					}
+				}
+			}
+		}
+		return nil, errNotFound
+	}
+
+	cmd := s.index.command(r.FormValue("command"))
+	if cmd == nil {
+		return nil, errNotFound
+	}
+	if name := r.FormValue("name"); name != "" {
+		if def := s.index.member(cmd, name); def != nil {
+			return def, nil
+		}
+		return nil, errNotFound
+	}
+	return cmd, nil
+}
+
+type hoverResultsByName []*hoverResult
+
+func (v hoverResultsByName) Len() int           { return len(v) }
+func (v hoverResultsByName) Less(i, j int) bool { return v[i].Name < v[j].Name }
+func (v hoverResultsByName) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+
+// firstParagraph returns the first paragraph of doc.
+func firstParagraph(doc string) string {
+	if i := strings.Index(doc, "\n\n"); i >= 0 {
+		return doc[:i]
+	}
+	return doc
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"strings"
+)
+
+// A shellToken is a word or operator of a shell command line.
+type shellToken struct {
+	Text string // the word with quotes and escapes removed, or the operator
+	Op   bool   // whether the token is a control or redirection operator
+
+	// Start and End are the byte offsets of the token in the source.
+	Start, End int
+}
+
+// quoted reports whether the token's source text differs from its
+// text, that is whether it contains quotes or escapes.
+func (t shellToken) quoted() bool { return t.End-t.Start != len(t.Text) }
+
+// shellOperators are the shell's control and redirection operators,
+// longest first so that they are matched greedily.
+var shellOperators = []string{
+	"<<-", "&&", "||", ";;", "<<", ">>", "<&", ">&", "<>", ">|",
+	";", "&", "|", "(", ")", "<", ">", "\n",
+}
+
+// tokenizeShell splits shell source into words and operators following
+// the token recognition rules of the POSIX shell command language.
+// Comments and here-document bodies are skipped, and newlines are
+// returned as "\n" operators. Parameter expansions and command
+// substitutions are kept as parts of words without being expanded.
+func tokenizeShell(src string) []shellToken {
+	var tokens []shellToken
+	var heredocs []string // delimiters of pending here-documents
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			i += 2
+			continue
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		}
+
+		if op := matchOperator(src[i:]); op != "" {
+			tokens = append(tokens, shellToken{Text: op, Op: true, Start: i, End: i + len(op)})
+			i += len(op)
+			if op == "\n" && len(heredocs) > 0 {
+				i = skipHeredocs(src, i, heredocs)
+				heredocs = nil
+			}
+			continue
+		}
+
+		// An IO number is part of the redirection operator that follows
+		// it, as in "2>/dev/null".
+		j := i
+		for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+			j++
+		}
+		if j > i && j < len(src) && (src[j] == '<' || src[j] == '>') {
+			op := matchOperator(src[j:])
+			tokens = append(tokens, shellToken{Text: src[i:j] + op, Op: true, Start: i, End: j + len(op)})
+			i = j + len(op)
+			continue
+		}
+
+		word, end := scanWord(src, i)
+		tokens = append(tokens, shellToken{Text: word, Start: i, End: end})
+		if n := len(tokens); n > 1 && (tokens[n-2].Text == "<<" || tokens[n-2].Text == "<<-") {
+			heredocs = append(heredocs, word)
+		}
+		i = end
+	}
+	return tokens
+}
+
+// matchOperator returns the operator at the start of s, or "".
+func matchOperator(s string) string {
+	for _, op := range shellOperators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// scanWord scans the word starting at src[start], returning it with
+// quotes and escapes removed, and the offset just past it.
+func scanWord(src string, start int) (string, int) {
+	var b strings.Builder
+	i := start
+	depth := 0          // nesting of $( ), $(( )), ${ } and the parentheses and braces in them
+	inBacktick := false // within ` `, which doesn't nest
+	for i < len(src) {
+		c := src[i]
+		if depth == 0 && !inBacktick && (c == ' ' || c == '\t' || matchOperator(src[i:]) != "") {
+			break
+		}
+		switch {
+		case c == '\\' && i+1 < len(src):
+			if src[i+1] != '\n' {
+				b.WriteByte(src[i+1])
+			}
+			i += 2
+			continue
+		case c == '\'':
+			end := strings.IndexByte(src[i+1:], '\'')
+			if end < 0 {
+				end = len(src) - i - 1
+			}
+			b.WriteString(src[i+1 : i+1+end])
+			i += end + 2
+			continue
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' && j+1 < len(src) && strings.IndexByte("$`\"\\\n", src[j+1]) >= 0 {
+					j++
+				}
+				b.WriteByte(src[j])
+				j++
+			}
+			i = j + 1
+			continue
+		case c == '$' && i+1 < len(src) && (src[i+1] == '(' || src[i+1] == '{'):
+			depth++
+			b.WriteString(src[i : i+2])
+			i += 2
+			continue
+		case depth > 0 && (c == '(' || c == '{'):
+			depth++
+		case depth > 0 && (c == ')' || c == '}'):
+			depth--
+		case c == '`':
+			inBacktick = !inBacktick
+		}
+		b.WriteByte(c)
+		i++
+	}
+	if i > len(src) {
+		i = len(src)
+	}
+	return b.String(), i
+}
+
+// skipHeredocs returns the offset just past the bodies of the
+// here-documents with the given delimiters, which start at src[i].
+func skipHeredocs(src string, i int, delims []string) int {
+	for _, delim := range delims {
+		for i < len(src) {
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				return len(src)
+			}
+			line := src[i : i+end]
+			i += end + 1
+			if strings.TrimLeft(line, "\t") == delim {
+				break
+			}
+		}
+	}
+	return i
+}
+
+// A redirect is a redirection of a simple command, such as ">/dev/null".
+type redirect struct {
+	Op     shellToken
+	Target shellToken
+}
+
+// A simpleCommand is a command name and its arguments, with variable
+// assignments and redirections set apart.
+type simpleCommand struct {
+	Words       []shellToken
+	Assignments []shellToken
+	Redirects   []redirect
+}
+
+// A pipeline is a sequence of simple commands joined by "|".
+type pipeline struct {
+	Commands []*simpleCommand
+}
+
+// shellReservedWords start or continue a compound command; the word
+// after them, if any, starts a simple command.
+var shellReservedWords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"do": true, "done": true, "while": true, "until": true,
+	"{": true, "}": true, "!": true, "esac": true,
+}
+
+// parseShell splits shell source into pipelines of simple commands.
+// Compound commands are flattened into the simple commands they
+// contain; for headers and case patterns are skipped.
+func parseShell(src string) []*pipeline {
+	var pipelines []*pipeline
+	cur := &pipeline{}
+	cmd := &simpleCommand{}
+	forHeader := false  // skipping "for name in word..."
+	caseHeader := false // skipping "case word in"
+	inPattern := false  // skipping a case pattern up to its ")"
+
+	endCommand := func() {
+		if len(cmd.Words) > 0 {
+			cur.Commands = append(cur.Commands, cmd)
+		}
+		cmd = &simpleCommand{}
+	}
+	endPipeline := func() {
+		endCommand()
+		if len(cur.Commands) > 0 {
+			pipelines = append(pipelines, cur)
+		}
+		cur = &pipeline{}
+	}
+
+	tokens := tokenizeShell(src)
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case inPattern:
+			if t.Text == ")" && t.Op {
+				inPattern = false
+			} else if t.Text == "esac" && !t.Op {
+				inPattern = false
+			}
+			continue
+		case caseHeader:
+			if t.Text == "in" && !t.Op {
+				caseHeader = false
+				inPattern = true
+			}
+			continue
+		case t.Op:
+			switch {
+			case t.Text == "|":
+				endCommand()
+			case strings.ContainsAny(t.Text, "<>"):
+				if i+1 < len(tokens) && !tokens[i+1].Op {
+					cmd.Redirects = append(cmd.Redirects, redirect{Op: t, Target: tokens[i+1]})
+					i++
+				}
+			default:
+				endPipeline()
+				forHeader = false
+				if t.Text == ";;" {
+					inPattern = true
+				}
+			}
+			continue
+		case forHeader:
+			continue
+		}
+
+		if len(cmd.Words) == 0 && !t.quoted() {
+			switch {
+			case t.Text == "for":
+				forHeader = true
+				continue
+			case t.Text == "case":
+				caseHeader = true
+				continue
+			case shellReservedWords[t.Text]:
+				continue
+			case isAssignment(t.Text):
+				cmd.Assignments = append(cmd.Assignments, t)
+				continue
+			}
+		}
+		cmd.Words = append(cmd.Words, t)
+	}
+	endPipeline()
+	return pipelines
+}
+
+// isAssignment reports whether word is a variable assignment such as
+// "LC_ALL=C".
+func isAssignment(word string) bool {
+	i := strings.IndexByte(word, '=')
+	if i <= 0 {
+		return false
+	}
+	for j, c := range word[:i] {
+		if !(c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || j > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// SignalHandling summarizes how a utility responds to signals, from the
+// ASYNCHRONOUS EVENTS section of its page.
+type SignalHandling struct {
+	// Default is set if the utility takes the default action on the
+	// signals other than those in Signals, as most do: interrupting it
+	// just terminates it.
+	Default bool `json:",omitempty"`
+
+	// Signals are the signals the utility ignores or handles itself.
+	Signals []*SignalAction `json:",omitempty"`
+}
+
+// A SignalAction is how a utility responds to a signal it doesn't take
+// the default action on.
+type SignalAction struct {
+	Signal string // e.g. "SIGINT"
+	Action string // "ignored" or "handled"
+	Note   string `json:",omitempty"` // the description of the action
+}
+
+var (
+	// signalName matches the names of signals, such as "SIGINT".
+	signalName = regexp.MustCompile(`\bSIG[A-Z0-9]+\b`)
+
+	// signalPhrase matches the signals named in words, as in "If an
+	// interrupt signal is received".
+	signalPhrase = regexp.MustCompile(`(?i)\b(interrupt|hangup|quit|termination)\s+signal\b`)
+
+	// listBullet matches the bullets of the items of a list, as in sh's
+	// "Default, except that: * It is unspecified whether ...".
+	listBullet = regexp.MustCompile(`(?:^|\s)[*•]\s+`)
+)
+
+// signalPhrases map the words of signalPhrase to the signals they name.
+var signalPhrases = map[string]string{
+	"interrupt": "SIGINT", "hangup": "SIGHUP", "quit": "SIGQUIT", "termination": "SIGTERM",
+}
+
+// A signalMention is a signal named in the text of a page.
+type signalMention struct {
+	Signal string // e.g. "SIGINT"
+
+	// Start and End are the byte offsets of the mention in the page text.
+	Start, End int
+}
+
+// signalCommands are the utilities that take signals as arguments, and
+// whose pages name them throughout.
+var signalCommands = map[string]bool{"kill": true, "trap": true}
+
+// signalMentions returns the signals named in the ASYNCHRONOUS EVENTS
+// section of p, by name or in words, and for signalCommands in their
+// DESCRIPTION, OPTIONS and OPERANDS as well.
+func (p *manPage) signalMentions() []*signalMention {
+	titles := []string{"ASYNCHRONOUS EVENTS"}
+	if signalCommands[p.Name] {
+		titles = append(titles, "DESCRIPTION", "OPTIONS", "OPERANDS")
+	}
+	var mentions []*signalMention
+	for _, title := range titles {
+		s := p.section(title)
+		if s == nil {
+			continue
+		}
+		text := p.Text[s.Start:s.End]
+		for _, loc := range signalName.FindAllStringIndex(text, -1) {
+			if name := text[loc[0]:loc[1]]; isSignal(name) {
+				mentions = append(mentions, &signalMention{Signal: name, Start: s.Start + loc[0], End: s.Start + loc[1]})
+			}
+		}
+		for _, m := range signalPhrase.FindAllStringSubmatchIndex(text, -1) {
+			name := signalPhrases[strings.ToLower(text[m[2]:m[3]])]
+			mentions = append(mentions, &signalMention{Signal: name, Start: s.Start + m[0], End: s.Start + m[1]})
+		}
+	}
+	return mentions
+}
+
+// signalHandling returns the summary of the ASYNCHRONOUS EVENTS section
+// of p, or nil if it has none. Signals are taken from the entries of
+// the section, as in ed's "SIGINT  The ed utility shall interrupt its
+// current activity", and from the sentences naming them otherwise, as in
+// nohup's "SIGHUP shall be ignored".
+func (p *manPage) signalHandling() *SignalHandling {
+	if p.section("ASYNCHRONOUS EVENTS") == nil {
+		return nil
+	}
+	normalize := func(s string) string {
+		_, s = stripMarginCodes(strings.Join(strings.Fields(s), " "))
+		return normalizeDashes(strings.TrimSpace(s))
+	}
+	text := normalize(p.body("ASYNCHRONOUS EVENTS"))
+	lower := strings.ToLower(text)
+
+	h := &SignalHandling{
+		Default: strings.HasPrefix(lower, "default") || strings.Contains(lower, "standard action for all signals"),
+	}
+	seen := map[string]bool{}
+	add := func(signal, note string) {
+		if seen[signal] {
+			return
+		}
+		seen[signal] = true
+		action := "handled"
+		if strings.Contains(strings.ToLower(note), "ignore") {
+			action = "ignored"
+		}
+		h.Signals = append(h.Signals, &SignalAction{Signal: signal, Action: action, Note: note})
+	}
+	for _, e := range p.entries("ASYNCHRONOUS EVENTS") {
+		if tag := normalize(e.Tag); isSignal(tag) {
+			add(tag, normalize(e.Doc))
+		}
+	}
+	var sentences []string
+	for _, item := range listBullet.Split(text, -1) {
+		sentences = append(sentences, splitSentences(item)...)
+	}
+	for _, sentence := range sentences {
+		for _, name := range signalName.FindAllString(sentence, -1) {
+			if isSignal(name) {
+				add(name, sentence)
+			}
+		}
+		for _, m := range signalPhrase.FindAllStringSubmatch(sentence, -1) {
+			add(signalPhrases[strings.ToLower(m[1])], sentence)
+		}
+	}
+	return h
+}
+
+// isSignal reports whether word is the name of a signal, such as
+// "SIGINT", rather than a heading such as "SIGNALS".
+func isSignal(word string) bool {
+	return signalName.FindString(word) == word && word != "" && word != "SIGNAL" && word != "SIGNALS"
+}
+
+// signalDefPath returns the path of the def of the named signal, such as
+// "SIGINT", in the "signal" namespace of the unit.
+func signalDefPath(name string) string {
+	return "signal/" + name
+}
+
+// isSignalDefPath reports whether path is that of the def of a signal.
+func isSignalDefPath(path string) bool {
+	return strings.HasPrefix(path, "signal/") && isSignal(strings.TrimPrefix(path, "signal/"))
+}
+
+// resolveSignalRefs points the refs to signals at the unit defining
+// them, since the signal defs come from pages that not every unit has:
+// the refs of a unit without <signal.h> resolve to another unit's defs,
+// as when POSIX pages are scanned in a hierarchy of their own. Refs to
+// signals that no unit defines are dropped.
+func resolveSignalRefs(output *graph.Output) {
+	defined := map[graph.DefKey]bool{}
+	definedBy := map[string]string{} // the first unit defining a signal, by path
+	for _, def := range output.Defs {
+		if def.Kind == "signal" {
+			defined[def.DefKey] = true
+			if _, ok := definedBy[def.Path]; !ok {
+				definedBy[def.Path] = def.Unit
+			}
+		}
+	}
+	refs := output.Refs[:0]
+	for _, r := range output.Refs {
+		if isSignalDefPath(r.DefPath) && !defined[graph.DefKey{UnitType: r.DefUnitType, Unit: r.DefUnit, Path: r.DefPath}] {
+			unit, ok := definedBy[r.DefPath]
+			if !ok {
+				continue
+			}
+			r.DefUnit = unit
+		}
+		refs = append(refs, r)
+	}
+	output.Refs = refs
+}
+
+// signalPages are the pages whose tables document the signals: POSIX's
+// <signal.h> and the Linux signal(7) overview.
+var signalPages = map[string]bool{"signal.h": true, "signal": true}
+
+// posixSignalNumbers are the signal numbers POSIX mandates, in the XSI
+// table of kill's -signal_number. Others vary between systems.
+var posixSignalNumbers = map[string]int{
+	"SIGHUP": 1, "SIGINT": 2, "SIGQUIT": 3, "SIGABRT": 6, "SIGKILL": 9,
+	"SIGALRM": 14, "SIGTERM": 15,
+}
+
+// signalActions map the codes of the default actions in signal tables,
+// POSIX's "T" and signal(7)'s "Term", to the actions.
+var signalActions = map[string]string{
+	"T": "terminate", "Term": "terminate",
+	"A": "abort", "Core": "abort",
+	"I": "ignore", "Ign": "ignore",
+	"S": "stop", "Stop": "stop",
+	"C": "continue", "Cont": "continue",
+}
+
+// A pageSignal is a signal documented in a table of a signal page.
+type pageSignal struct {
+	Name   string // e.g. "SIGINT"
+	Number int    // the number POSIX mandates, or 0
+	Action string // the default action, e.g. "terminate", or ""
+	Doc    string
+
+	// Start and End are the byte offsets of the name in the page text.
+	Start, End int
+}
+
+// signals returns the signals documented in the tables of p, if it is
+// one of signalPages. A row names the signal in one of its first two
+// cells, as in "│SIGINT │ T │ Terminal interrupt signal. │" or
+// "│2 │ SIGINT │", and has its description in the last; rows with an
+// empty first cell continue the description of the previous signal.
+// Each signal is taken from the first table documenting it, so that
+// signal(7)'s per-architecture numbering is ignored.
+func (p *manPage) signals() []*pageSignal {
+	if !signalPages[p.Name] {
+		return nil
+	}
+	var signals []*pageSignal
+	seen := map[string]bool{}
+	var last *pageSignal
+	for _, l := range splitLines(p.Text, 0) {
+		cells := tableCells(l.Text)
+		if len(cells) < 2 {
+			last = nil
+			continue
+		}
+		if cells[0] == "" {
+			if last != nil {
+				if doc := cells[len(cells)-1]; doc != "" {
+					last.Doc += " " + doc
+				}
+			}
+			continue
+		}
+		last = nil
+		name := -1
+		for i := 0; i < 2; i++ {
+			if isSignal(cells[i]) {
+				name = i
+				break
+			}
+		}
+		if name < 0 || seen[cells[name]] {
+			continue
+		}
+		seen[cells[name]] = true
+		start := l.Offset + strings.Index(l.Text, cells[name])
+		s := &pageSignal{
+			Name:   cells[name],
+			Number: posixSignalNumbers[cells[name]],
+			Start:  start,
+			End:    start + len(cells[name]),
+		}
+		for _, c := range cells[name+1:] {
+			if a, ok := signalActions[c]; ok {
+				s.Action = a
+				break
+			}
+		}
+		if doc := cells[len(cells)-1]; doc != s.Name && signalActions[doc] == "" {
+			s.Doc = doc
+		}
+		signals = append(signals, s)
+		last = s
+	}
+	return signals
+}
+
+// tableCells splits a line of a table into its trimmed cells: those
+// between the "│" of a boxed table, or those separated by tabs or runs
+// of spaces otherwise. It returns nil for other lines.
+func tableCells(line string) []string {
+	line = strings.TrimRight(line, "\n")
+	var cells []string
+	switch {
+	case strings.Contains(line, "│"):
+		cells = strings.Split(line, "│")
+		if len(cells) < 3 {
+			return nil
+		}
+		cells = cells[1 : len(cells)-1]
+	case strings.Contains(strings.TrimLeft(line, " "), "\t"):
+		cells = strings.Split(strings.TrimLeft(line, " "), "\t")
+	default:
+		cells = tableSpaces.Split(strings.TrimSpace(line), -1)
+	}
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(c)
+	}
+	return cells
+}
+
+// tableSpaces matches the runs of spaces separating the cells of a table
+// without tabs or boxes.
+var tableSpaces = regexp.MustCompile(` {2,}`)
+
+// signalNameOf returns the name of the signal word refers to as used
+// with kill and trap: "TERM", "SIGTERM" and "term" name SIGTERM, as do
+// the numbers POSIX mandates, such as "15". It returns "" otherwise,
+// as for trap's conditions EXIT and 0, which are not signals.
+func signalNameOf(word string) string {
+	if n, err := strconv.Atoi(word); err == nil {
+		for name, num := range posixSignalNumbers {
+			if num == n {
+				return name
+			}
+		}
+		return ""
+	}
+	name := strings.ToUpper(word)
+	if name == "EXIT" {
+		return ""
+	}
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if !isSignal(name) {
+		return ""
+	}
+	return name
+}
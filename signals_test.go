@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestResolveSignalRefs(t *testing.T) {
+	def := func(unit, path, kind string) *graph.Def {
+		return &graph.Def{DefKey: graph.DefKey{UnitType: "ManPages", Unit: unit, Path: path}, Kind: kind}
+	}
+	ref := func(unit, path string) *graph.Ref {
+		return &graph.Ref{DefUnitType: "ManPages", DefUnit: unit, DefPath: path, UnitType: "ManPages", Unit: unit}
+	}
+	output := &graph.Output{
+		Defs: []*graph.Def{
+			def("posix", "signal/SIGINT", "signal"),
+			def("posix", "signal/SIGTERM", "signal"),
+			def("linux", "signal/SIGTERM", "signal"),
+			def("gnu", "man1/timeout.1.gz/timeout", "command"),
+		},
+		Refs: []*graph.Ref{
+			ref("posix", "signal/SIGINT"),
+			ref("gnu", "signal/SIGINT"),
+			ref("gnu", "signal/SIGTERM"),
+			ref("linux", "signal/SIGTERM"),
+			ref("gnu", "signal/SIGWINCH"),
+			ref("gnu", "man1/timeout.1.gz/timeout"),
+		},
+	}
+	resolveSignalRefs(output)
+
+	want := []string{
+		"posix signal/SIGINT",
+		"posix signal/SIGINT",
+		"posix signal/SIGTERM",
+		"linux signal/SIGTERM",
+		"gnu man1/timeout.1.gz/timeout",
+	}
+	if len(output.Refs) != len(want) {
+		t.Fatalf("got %d refs, want %d", len(output.Refs), len(want))
+	}
+	for i, r := range output.Refs {
+		if got := r.DefUnit + " " + r.DefPath; got != want[i] {
+			t.Errorf("ref %d resolves to %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestSignalNameOf(t *testing.T) {
+	tests := map[string]string{
+		"TERM": "SIGTERM", "term": "SIGTERM", "SIGTERM": "SIGTERM", "15": "SIGTERM",
+		"9": "SIGKILL", "0": "", "42": "", "": "", "EXIT": "", "exit": "",
+	}
+	for word, want := range tests {
+		if got := signalNameOf(word); got != want {
+			t.Errorf("signalNameOf(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+)
+
+// argTypeWords map the words used in option-argument and operand
+// placeholders, such as "source_file" or "DIR", to argument types.
+var argTypeWords = map[string]string{
+	"file": "file", "files": "file", "pathname": "file", "path": "file",
+	"archive": "file", "filename": "file",
+
+	"directory": "directory", "dir": "directory", "directories": "directory",
+
+	"number": "number", "num": "number", "n": "number", "count": "number",
+	"integer": "number", "cols": "number", "lines": "number",
+	"bytes": "number", "blocks": "number", "size": "number", "depth": "number",
+	"level": "number", "width": "number", "offset": "number",
+
+	"pattern": "pattern", "patterns": "pattern", "regex": "pattern",
+	"regexp": "pattern", "bre": "BRE", "ere": "ERE",
+
+	"utility": "command", "command": "command", "cmd": "command",
+	"program": "command",
+
+	"user": "user", "owner": "user", "login": "user", "uid": "user",
+	"username": "user",
+
+	"group": "group", "gid": "group",
+
+	"mode": "mode",
+
+	"date": "date", "time": "date", "datetime": "date", "timestamp": "date",
+
+	"signal": "signal", "sig": "signal",
+
+	// Words that name a kind of value of their own, so that the words
+	// before them don't decide the type: "TIME_STYLE" is not a date.
+	"style": "", "format": "",
+}
+
+// argTypePhrases classify arguments by their documentation when the
+// placeholder is not telling, as with "string" or "operand". They are
+// tried in order, most specific first.
+var argTypePhrases = []struct{ phrase, typ string }{
+	{"extended regular expression", "ERE"},
+	{"basic regular expression", "BRE"},
+	{"signal name", "signal"},
+	{"signal number", "signal"},
+	{"symbolic mode", "mode"},
+	{"file mode", "mode"},
+	{"user name", "user"},
+	{"user id", "user"},
+	{"group name", "group"},
+	{"group id", "group"},
+	{"name of a utility", "command"},
+	{"utility name", "command"},
+	{"pathname of a directory", "directory"},
+	{"pathname of an existing directory", "directory"},
+	{"decimal integer", "number"},
+	{"pathname", "file"},
+}
+
+// argType classifies an option-argument or operand as one of "file",
+// "directory", "number", "pattern", "BRE", "ERE", "command", "user",
+// "group", "mode", "date" or "signal", from its placeholder name (e.g.
+// "number" in "-n number") and its documentation. It returns "" if the
+// type can't be told.
+func argType(placeholder, doc string) string {
+	// The last word is usually the one that matters: "source_file",
+	// "signal_name".
+	words := strings.FieldsFunc(strings.ToLower(placeholder), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	})
+	for i := len(words) - 1; i >= 0; i-- {
+		if typ, ok := argTypeWords[words[i]]; ok {
+			return refinePattern(typ, doc)
+		}
+	}
+
+	doc = strings.ToLower(strings.Join(strings.Fields(doc), " "))
+	for _, p := range argTypePhrases {
+		if strings.Contains(doc, p.phrase) {
+			return p.typ
+		}
+	}
+	return ""
+}
+
+// refinePattern narrows the "pattern" type down to the regular
+// expression dialect named in doc, if any.
+func refinePattern(typ, doc string) string {
+	if typ != "pattern" {
+		return typ
+	}
+	doc = strings.ToLower(doc)
+	switch {
+	case strings.Contains(doc, "extended regular expression"):
+		return "ERE"
+	case strings.Contains(doc, "basic regular expression"):
+		return "BRE"
+	}
+	return typ
+}
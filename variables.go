@@ -0,0 +1,153 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+// specialParameters are the names POSIX gives the special parameters of
+// the shell, as in "$?". They also name the defs, whose paths can't
+// have "?" or "#" as a component.
+var specialParameters = map[string]string{
+	"@": "at", "*": "asterisk", "#": "number-sign", "?": "question-mark",
+	"-": "hyphen", "$": "dollar-sign", "!": "exclamation-mark", "0": "zero",
+}
+
+// variableName matches the tags of entries documenting shell variables,
+// such as "IFS" or "LC_ALL".
+var variableName = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// A shellVariable is a special parameter or variable of the shell.
+type shellVariable struct {
+	Name    string // e.g. "?" or "IFS"
+	Keyword string // "special parameter" or "variable"
+	Doc     string
+
+	// Start and End are the byte offsets of Name in the page text.
+	Start, End int
+}
+
+// shellVariables returns the special parameters and variables of the
+// shell that p documents. They come from the shell command language
+// page, whose ENVIRONMENT VARIABLES section lists the shell's
+// variables, and from the pages of built-ins, such as OPTIND and OPTARG
+// for getopts. The locale variables every page lists are only taken
+// from the shell command language page.
+func (p *manPage) shellVariables() []*shellVariable {
+	var vars []*shellVariable
+	add := func(entries []*pageEntry, keyword string, locale bool) {
+		for _, e := range entries {
+			name := normalizeDashes(e.Tag)
+			if keyword == "special parameter" {
+				if _, ok := specialParameters[name]; !ok {
+					continue
+				}
+			} else if !variableName.MatchString(name) || (!locale && isLocaleVariable(name)) {
+				continue
+			}
+			vars = append(vars, &shellVariable{
+				Name:    name,
+				Keyword: keyword,
+				Doc:     e.Doc,
+				Start:   e.TagStart,
+				End:     e.TagEnd,
+			})
+		}
+	}
+
+	chapters := p.chapters(shellChapter)
+	for _, c := range chapters {
+		lines := splitLines(p.Text[c.Start:c.End], c.Start)
+		switch c.Title {
+		case "special parameters":
+			add(p.entriesIn(lines), "special parameter", true)
+		case "shell variables":
+			add(p.entriesIn(lines), "variable", true)
+		}
+	}
+	switch {
+	case p.Name == "sh" || len(chapters) > 0:
+		add(p.entries("ENVIRONMENT VARIABLES"), "variable", true)
+	case contains(specialBuiltins, p.Name) || contains(regularBuiltins, p.Name):
+		add(p.entries("ENVIRONMENT VARIABLES"), "variable", false)
+	}
+
+	// A variable documented twice on the page, as in both Shell
+	// Variables and ENVIRONMENT VARIABLES, is defined by the first.
+	seen := map[string]bool{}
+	unique := vars[:0]
+	for _, v := range vars {
+		if !seen[v.Name] {
+			seen[v.Name] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// isLocaleVariable reports whether name is one of the internationalization
+// variables, such as LANG or LC_ALL.
+func isLocaleVariable(name string) bool {
+	return name == "LANG" || name == "NLSPATH" || strings.HasPrefix(name, "LC_")
+}
+
+// shellVariablePath returns the component naming v in def paths.
+func shellVariablePath(v *shellVariable) string {
+	if alt, ok := specialParameters[v.Name]; ok {
+		return alt
+	}
+	return v.Name
+}
+
+// linkVariables keeps one def of each shell variable among the defs of a
+// unit starting at first, and turns the others into refs to it. The def
+// kept is the one from the page documenting the most variables, which is
+// the shell command language page, so that a built-in such as getopts
+// refers to the OPTIND the shell defines rather than redefining it.
+// Variables only built-ins document keep the def of the first of them.
+func linkVariables(output *graph.Output, first int) {
+	defs := output.Defs[first:]
+	perFile := map[string]int{}
+	for _, def := range defs {
+		if isVariablePath(def.Path) {
+			perFile[def.File]++
+		}
+	}
+	kept := map[string]*graph.Def{}
+	for _, def := range defs {
+		if !isVariablePath(def.Path) {
+			continue
+		}
+		if k := kept[def.Path]; k == nil || perFile[def.File] > perFile[k.File] {
+			kept[def.Path] = def
+		}
+	}
+
+	unique := defs[:0]
+	for _, def := range defs {
+		k := kept[def.Path]
+		if k == nil || k == def {
+			unique = append(unique, def)
+			continue
+		}
+		output.Refs = append(output.Refs, &graph.Ref{
+			DefUnitType: k.UnitType,
+			DefUnit:     k.Unit,
+			DefPath:     k.Path,
+			UnitType:    def.UnitType,
+			Unit:        def.Unit,
+			File:        def.File,
+			Start:       def.DefStart,
+			End:         def.DefEnd,
+		})
+	}
+	output.Defs = output.Defs[:first+len(unique)]
+}
+
+// isVariablePath reports whether path is that of a shell variable, as
+// opposed to the built-in variables of awk.
+func isVariablePath(path string) bool {
+	return strings.HasPrefix(path, "variable/")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+)
+
+func TestLinkVariables(t *testing.T) {
+	def := func(file, path, kind string, start uint32) *graph.Def {
+		return &graph.Def{DefKey: graph.DefKey{UnitType: "ManPages", Unit: "posix", Path: path}, Kind: kind, File: file, DefStart: start, DefEnd: start + 1}
+	}
+	output := &graph.Output{
+		Defs: []*graph.Def{
+			def("getopts.1p.txt", "getopts.1p.txt/getopts", "command", 0),
+			def("getopts.1p.txt", "variable/OPTIND", "variable", 10),
+			def("getopts.1p.txt", "variable/OPTARG", "variable", 20),
+			def("sh.1p.txt", "sh.1p.txt/sh", "command", 0),
+			def("sh.1p.txt", "variable/question-mark", "variable", 30),
+			def("sh.1p.txt", "variable/IFS", "variable", 40),
+			def("sh.1p.txt", "variable/OPTIND", "variable", 50),
+			def("awk.1p.txt", "awk.1p.txt/awk/variable/NR", "variable", 60),
+		},
+	}
+	linkVariables(output, 0)
+
+	var defs, refs []string
+	for _, d := range output.Defs {
+		defs = append(defs, d.File+" "+d.Path)
+	}
+	for _, r := range output.Refs {
+		refs = append(refs, r.File+" "+r.DefPath)
+	}
+	wantDefs := []string{
+		"getopts.1p.txt getopts.1p.txt/getopts",
+		"getopts.1p.txt variable/OPTARG",
+		"sh.1p.txt sh.1p.txt/sh",
+		"sh.1p.txt variable/question-mark",
+		"sh.1p.txt variable/IFS",
+		"sh.1p.txt variable/OPTIND",
+		"awk.1p.txt awk.1p.txt/awk/variable/NR",
+	}
+	if got, want := strings.Join(defs, "\n"), strings.Join(wantDefs, "\n"); got != want {
+		t.Errorf("got defs\n%s\nwant\n%s", got, want)
+	}
+	if got, want := strings.Join(refs, "\n"), "getopts.1p.txt variable/OPTIND"; got != want {
+		t.Errorf("got refs\n%s\nwant\n%s", got, want)
+	}
+	if len(output.Refs) == 1 && output.Refs[0].Start != 10 {
+		t.Errorf("got ref at %d, want 10", output.Refs[0].Start)
+	}
+}
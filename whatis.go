@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/srclib/graph"
+	"sourcegraph.com/sourcegraph/srclib/unit"
+)
+
+func init() {
+	_, err := flagParser.AddCommand("whatis",
+		"generate a whatis index",
+		"Generate a plain text whatis index of the man pages under the current directory, using the same parsing as graph.",
+		&whatisCmd,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type WhatisCmd struct {
+	Output string `short:"o" long:"output" description:"write the index to this file instead of STDOUT"`
+}
+
+var whatisCmd WhatisCmd
+
+func (c *WhatisCmd) Execute(args []string) error {
+	units, err := scan(getCWD())
+	if err != nil {
+		return fmt.Errorf("scanning the path failed with: %s", err)
+	}
+
+	var entries []*whatisEntry
+	for _, u := range units {
+		if u.Config["format"] == "whatis" || u.Config["locale"] != "" {
+			continue
+		}
+		for _, f := range u.Files {
+			// Pages that graph skips are left out of the index too.
+			p, err := readPage(f)
+			if err != nil {
+				log.Printf("Skipping %s: %s", f, err)
+				continue
+			}
+			names, summary := p.summary()
+			if len(names) == 0 {
+				continue
+			}
+			entries = append(entries, &whatisEntry{Names: names, Section: p.Section, Summary: summary})
+		}
+	}
+	sort.Sort(whatisEntries(entries))
+
+	out := os.Stdout
+	if c.Output != "" {
+		if out, err = os.Create(c.Output); err != nil {
+			return fmt.Errorf("creating %s failed with: %s", c.Output, err)
+		}
+		defer out.Close()
+	}
+	w := bufio.NewWriter(out)
+	for _, e := range entries {
+		fmt.Fprintln(w, e)
+	}
+	return w.Flush()
+}
+
+// A whatisEntry is one line of a whatis database, such as
+// "ls (1p) - list directory contents".
+type whatisEntry struct {
+	Names   []string
+	Section string
+	Summary string
+
+	// NameOffsets are the byte offsets of Names in the database.
+	NameOffsets []int
+}
+
+func (e *whatisEntry) String() string {
+	return fmt.Sprintf("%-20s - %s", fmt.Sprintf("%s (%s)", strings.Join(e.Names, ", "), e.Section), e.Summary)
+}
+
+type whatisEntries []*whatisEntry
+
+func (v whatisEntries) Len() int      { return len(v) }
+func (v whatisEntries) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v whatisEntries) Less(i, j int) bool {
+	if v[i].Names[0] != v[j].Names[0] {
+		return v[i].Names[0] < v[j].Names[0]
+	}
+	return v[i].Section < v[j].Section
+}
+
+// whatisLine matches a line of a plain text whatis database, in either
+// the man-db ("ls (1) - ...") or BSD ("ls(1) - ...") format.
+var whatisLine = regexp.MustCompile(`^(.+?)\s*\(([^)]+)\)\s+-+\s+(.*)$`)
+
+// parseWhatis reads the entries of a plain text whatis database. Lines
+// that are not whatis entries, or name no page, are skipped.
+func parseWhatis(r io.Reader) ([]*whatisEntry, error) {
+	var entries []*whatisEntry
+	offset := 0
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if m := whatisLine.FindStringSubmatch(strings.TrimRight(line, "\r\n")); m != nil {
+			e := &whatisEntry{Section: m[2], Summary: strings.TrimSpace(m[3])}
+			cursor := 0
+			for _, n := range strings.Split(m[1], ",") {
+				if n = strings.TrimSpace(n); n != "" {
+					cursor += strings.Index(line[cursor:], n)
+					e.Names = append(e.Names, n)
+					e.NameOffsets = append(e.NameOffsets, offset+cursor)
+					cursor += len(n)
+				}
+			}
+			if len(e.Names) > 0 {
+				entries = append(entries, e)
+			}
+		}
+		offset += len(line)
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isWhatisFile reports whether the file at path is a plain text whatis
+// database.
+func isWhatisFile(path string) bool {
+	base := filepath.Base(path)
+	return base == "whatis" || base == "whatis.db" || strings.HasSuffix(base, ".whatis")
+}
+
+// isCommandSection reports whether the manual section documents
+// commands (user commands, games and administration commands).
+func isCommandSection(section string) bool {
+	return strings.HasPrefix(section, "1") || strings.HasPrefix(section, "6") || strings.HasPrefix(section, "8")
+}
+
+// graphWhatis produces command defs, documented with their one-line
+// summaries, from the whatis database at file.
+func graphWhatis(u *unit.SourceUnit, file string, output *graph.Output) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("Failed to open file %s: %s", file, err)
+	}
+	defer f.Close()
+
+	entries, err := parseWhatis(f)
+	if err != nil {
+		return fmt.Errorf("Failed to read whatis database %s: %s", file, err)
+	}
+	for _, e := range entries {
+		if !isCommandSection(e.Section) {
+			continue
+		}
+		for i, name := range e.Names {
+			data, err := json.Marshal(DefData{
+				Name:    name,
+				Kind:    "command",
+				Keyword: "command",
+			})
+			if err != nil {
+				return err
+			}
+			output.Defs = append(output.Defs, &graph.Def{
+				DefKey: graph.DefKey{
+					UnitType: "ManPages",
+					Unit:     u.Name,
+					Path:     file + "/" + e.Section + "/" + name,
+				},
+				Exported: true,
+				Data:     data,
+				Name:     name,
+				Kind:     "command",
+				File:     file,
+				DefStart: uint32(e.NameOffsets[i]),
+				DefEnd:   uint32(e.NameOffsets[i] + len(name)),
+				Docs:     []*graph.DefDoc{{Format: "text/plain", Data: e.Summary}},
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseWhatis(t *testing.T) {
+	db := "ls (1p)              - list directory contents\n" +
+		"cat(1) - concatenate files\n" +
+		"gzip, gunzip (1)     - compress or expand files\n" +
+		", (1) - no name\n" +
+		"not an entry\n"
+	entries, err := parseWhatis(strings.NewReader(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(whatisEntries(entries))
+	var got []string
+	for _, e := range entries {
+		got = append(got, strings.Join(e.Names, ",")+"("+e.Section+") "+e.Summary)
+		for i, name := range e.Names {
+			if o := e.NameOffsets[i]; db[o:o+len(name)] != name {
+				t.Errorf("%s is at offset %d", name, o)
+			}
+		}
+	}
+	want := "cat(1) concatenate files|gzip,gunzip(1) compress or expand files|ls(1p) list directory contents"
+	if strings.Join(got, "|") != want {
+		t.Errorf("got entries %s, want %s", strings.Join(got, "|"), want)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+)
+
+// wrapperUtilities are the utilities that run another command given by
+// their operands, as in "xargs rm -f" or "nice -n 5 make", or by the
+// arguments of a primary, as in find's "-exec rm {} ;". The operands or
+// primaries that introduce the nested command are marked in their defs,
+// so that its words can be bound to the defs of its own page.
+var wrapperUtilities = map[string]bool{
+	"xargs": true, "env": true, "nohup": true, "nice": true, "time": true,
+	"timeout": true, "command": true, "exec": true, "find": true,
+}
+
+// commandTerminators returns the words that may end the nested command
+// introduced by a primary whose tag continues with arg, such as ";" in
+// "utility_name [argument ...] ;" or "+" in "command {} +".
+func commandTerminators(arg string) []string {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return nil
+	}
+	switch last := strings.Trim(fields[len(fields)-1], `\'"`); last {
+	case ";", "+":
+		return []string{last}
+	}
+	return nil
+}
+
+// addTerminators adds the terminators of the forms of t listed in lines,
+// such as POSIX find's "-exec utility_name [argument ...] ;" and "-exec
+// utility_name [argument ...] {} +", which share one doc.
+func addTerminators(t *pageOption, lines []pageLine) {
+	for _, l := range lines {
+		fields := strings.Fields(normalizeDashes(l.Text))
+		if len(fields) < 2 || fields[0] != t.Name {
+			continue
+		}
+		for _, term := range commandTerminators(strings.Join(fields[1:], " ")) {
+			if !contains(t.Terminators, term) {
+				t.Terminators = append(t.Terminators, term)
+			}
+		}
+	}
+}
+
+// synopsisOperands returns the operands named in the first form of p's
+// SYNOPSIS, such as "COMMAND" and "ARG" in "nohup COMMAND [ARG]...". It
+// is used for the pages of wrapper utilities that have no OPERANDS
+// section, as GNU's don't, so that the operand introducing the nested
+// command has a def. Options and their arguments are skipped.
+func (p *manPage) synopsisOperands() []*pageOption {
+	var operands []*pageOption
+	seen := map[string]bool{}
+	started := false
+	depth, skipDepth, skipping := 0, 0, false
+	for _, l := range p.lines("SYNOPSIS") {
+		offset := 0
+		for _, f := range strings.Fields(l.Text) {
+			i := offset + strings.Index(l.Text[offset:], f)
+			offset = i + len(f)
+			if f == p.Name {
+				if started {
+					return operands
+				}
+				started = true
+				continue
+			}
+			if !started {
+				continue
+			}
+
+			name := strings.TrimLeft(f, "[")
+			opens := len(f) - len(name)
+			trimmed := strings.TrimRight(name, "].")
+			closes := strings.Count(name[len(trimmed):], "]")
+			name = normalizeDashes(trimmed)
+
+			d0 := depth
+			depth += opens - closes
+			switch {
+			case skipping:
+			case strings.HasPrefix(name, "-"):
+				skipping, skipDepth = true, d0
+			case name == "" || strings.EqualFold(name, "option") || strings.EqualFold(name, "options") || seen[name]:
+			default:
+				seen[name] = true
+				start := l.Offset + i + opens
+				operands = append(operands, &pageOption{Name: name, Start: start, End: start + len(name)})
+			}
+			if skipping && depth <= skipDepth {
+				skipping = false
+			}
+		}
+	}
+	return operands
+}